@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// converter describes how to move a value of a particular Go type across
+// the Risor/Go boundary in a generated wrapper.
+type converter struct {
+	ReadFunc string // object.Read* helper used to pull the value out of an object.Object
+	CastFunc string // Go conversion applied to the value ReadFunc returns
+	NewFunc  string // object.New* constructor used to wrap a returned value
+	Import   string // package that must be imported for CastFunc's type to resolve
+
+	// CastMinValue and CastMaxValue, when set, are Go expressions (e.g.
+	// "math.MinInt32") bounding the value ReadFunc returns before CastFunc
+	// narrows it, so an out-of-range script value produces a Risor error
+	// instead of silently wrapping. Only meaningful when CastFunc narrows
+	// AsInt's int64 or AsFloat's float64 to a smaller width.
+	CastMinValue string
+	CastMaxValue string
+}
+
+// builtinConverters covers the small set of scalar Go types modgen can bind
+// without any additional configuration.
+var builtinConverters = map[string]converter{
+	"string": {ReadFunc: "AsString", NewFunc: "NewString"},
+	"bool":   {ReadFunc: "AsBool", NewFunc: "NewBool"},
+	"int":    {ReadFunc: "AsInt", CastFunc: "int", NewFunc: "NewInt"},
+	"int32": {
+		ReadFunc: "AsInt", CastFunc: "int32", NewFunc: "NewInt",
+		CastMinValue: "math.MinInt32", CastMaxValue: "math.MaxInt32",
+	},
+	"int64": {ReadFunc: "AsInt", NewFunc: "NewInt"},
+	"float32": {
+		ReadFunc: "AsFloat", CastFunc: "float32", NewFunc: "NewFloat",
+		CastMinValue: "-math.MaxFloat32", CastMaxValue: "math.MaxFloat32",
+	},
+	"float64": {ReadFunc: "AsFloat", NewFunc: "NewFloat"},
+}
+
+// defaultConverters covers idiomatic Go types outside the language's scalar
+// kinds. Unlike builtinConverters, binding one of these pulls in the
+// converter's Import.
+var defaultConverters = map[string]converter{
+	"time.Duration":  {ReadFunc: "AsInt", CastFunc: "time.Duration", NewFunc: "NewInt", Import: "time"},
+	"time.Time":      {ReadFunc: "AsTime", NewFunc: "NewTime", Import: "time"},
+	"[]byte":         {ReadFunc: "AsBytes", NewFunc: "NewByteSlice"},
+	"map[string]any": {ReadFunc: "AsMap", NewFunc: "NewMap"},
+	"io.Reader":      {ReadFunc: "AsReader", NewFunc: "NewReader", Import: "io"},
+	"io.Writer":      {ReadFunc: "AsWriter", NewFunc: "NewWriter", Import: "io"},
+}
+
+// lookupConverter resolves a Go type expression to a converter, checking the
+// module's own registry (populated from //risor:converter directives) before
+// falling back to defaultConverters and finally builtinConverters.
+func (m *Module) lookupConverter(typeName string) (converter, bool) {
+	if conv, ok := m.converters[typeName]; ok {
+		return conv, true
+	}
+	if conv, ok := defaultConverters[typeName]; ok {
+		return conv, true
+	}
+	conv, ok := builtinConverters[typeName]
+	return conv, ok
+}
+
+func (m *Module) paramFromType(name string, typeExpr any) (*Param, error) {
+	typeName := m.sprintExpr(typeExpr)
+	conv, ok := m.lookupConverter(typeName)
+	if !ok {
+		return nil, fmt.Errorf("unsupported parameter type %q for %q (register one with //risor:converter)", typeName, name)
+	}
+	if conv.Import != "" {
+		m.addImport(conv.Import)
+	}
+	if conv.CastMinValue != "" || conv.CastMaxValue != "" {
+		m.addImport("math")
+	}
+	return &Param{
+		Name:         name,
+		ReadFunc:     conv.ReadFunc,
+		CastFunc:     conv.CastFunc,
+		CastMinValue: conv.CastMinValue,
+		CastMaxValue: conv.CastMaxValue,
+	}, nil
+}
+
+func (m *Module) returnFromType(typeExpr any) (*ReturnInfo, error) {
+	typeName := m.sprintExpr(typeExpr)
+	conv, ok := m.lookupConverter(typeName)
+	if !ok {
+		return nil, fmt.Errorf("unsupported return type %q (register one with //risor:converter)", typeName)
+	}
+	if conv.Import != "" {
+		m.addImport(conv.Import)
+	}
+	// A converter's CastFunc narrows a read value to the parameter's Go
+	// type; on the way back the value is widened to what NewFunc takes.
+	ret := &ReturnInfo{NewFunc: conv.NewFunc}
+	switch {
+	case conv.CastFunc != "" && conv.ReadFunc == "AsInt":
+		ret.CastFunc = "int64"
+	case conv.CastFunc != "" && conv.ReadFunc == "AsFloat":
+		ret.CastFunc = "float64"
+	default:
+		ret.CastFunc = conv.CastFunc
+	}
+	return ret, nil
+}
+
+// parseConverterDirective looks for `//risor:converter` directive comments
+// of the form:
+//
+//	//risor:converter type=url.URL read_func=AsURL new_func=NewURL import=net/url
+//
+// and registers the resulting converter under the given Go type expression,
+// so that a later parameter or return type using it is bindable without a
+// hand-written stub. This lets a module supply converters for domain types
+// (a user struct, or a stdlib type like url.URL) alongside its own code.
+func (m *Module) parseConverterDirective(file *ast.File) error {
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			after, ok := cutPrefixAndSpace(comment.Text, "//risor:converter")
+			if !ok {
+				continue
+			}
+			conv, typeName, err := parseConverterFields(after)
+			if err != nil {
+				return fmt.Errorf("invalid //risor:converter directive: %w", err)
+			}
+			if m.converters == nil {
+				m.converters = map[string]converter{}
+			}
+			m.converters[typeName] = conv
+		}
+	}
+	return nil
+}
+
+func parseConverterFields(fields string) (converter, string, error) {
+	var conv converter
+	var typeName string
+	for _, field := range strings.Fields(fields) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return conv, "", fmt.Errorf("expected key=value, got %q", field)
+		}
+		switch key {
+		case "type":
+			typeName = value
+		case "read_func":
+			conv.ReadFunc = value
+		case "cast_func":
+			conv.CastFunc = value
+		case "new_func":
+			conv.NewFunc = value
+		case "import":
+			conv.Import = value
+		default:
+			return conv, "", fmt.Errorf("unknown field %q", key)
+		}
+	}
+	if typeName == "" {
+		return conv, "", fmt.Errorf("missing type=")
+	}
+	return conv, typeName, nil
+}
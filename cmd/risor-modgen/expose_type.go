@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// exposedType groups the methods found on a single Go receiver type that was
+// marked with a `//risor:generate expose-type <Name>` directive on its type
+// declaration. Each method becomes a builtin dispatched through the type's
+// generated `<Name>Proxy` wrapper.
+type exposedType struct {
+	Name    string // Go receiver type name, e.g. "Client"
+	Pointer bool   // true once a method with a pointer receiver is seen
+	Methods []ExportedFunc
+}
+
+func (e *exposedType) ProxyName() string       { return e.Name + "Proxy" }
+func (e *exposedType) ConstructorName() string { return "New" + e.ProxyName() }
+
+// parseGenDecl looks for `//risor:generate expose-type <Name>` directives on
+// type declarations, registering the named type so that methods discovered
+// later by parseReceiverFunc are grouped under it.
+func (m *Module) parseGenDecl(decl *ast.GenDecl) error {
+	if decl.Tok != token.TYPE {
+		return nil
+	}
+	doc := decl.Doc
+	for _, spec := range decl.Specs {
+		typeSpec, ok := spec.(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+		specDoc := typeSpec.Doc
+		if specDoc == nil {
+			specDoc = doc
+		}
+		if specDoc == nil {
+			continue
+		}
+		for _, comment := range specDoc.List {
+			after, ok := cutPrefixAndSpace(comment.Text, "//risor:generate")
+			if !ok {
+				continue
+			}
+			fields, ok := strings.CutPrefix(after, "expose-type ")
+			if !ok {
+				continue
+			}
+			name := strings.TrimSpace(fields)
+			if name != typeSpec.Name.Name {
+				return fmt.Errorf("expose-type directive %q does not match type %q", name, typeSpec.Name.Name)
+			}
+			m.registerExposedType(name)
+		}
+	}
+	return nil
+}
+
+func (m *Module) registerExposedType(name string) *exposedType {
+	if m.exposedTypes == nil {
+		m.exposedTypes = map[string]*exposedType{}
+	}
+	t, ok := m.exposedTypes[name]
+	if !ok {
+		t = &exposedType{Name: name}
+		m.exposedTypes[name] = t
+	}
+	return t
+}
+
+// parseReceiverFunc handles a *ast.FuncDecl with a receiver, grouping it
+// under its receiver's exposedType (registered via a prior expose-type
+// directive). Methods on types without a directive are ignored, the same
+// way unexported free functions are ignored by parseFuncDecl.
+func (m *Module) parseReceiverFunc(decl *ast.FuncDecl) error {
+	if !decl.Name.IsExported() {
+		return nil
+	}
+	recvField := decl.Recv.List[0]
+	recvName, pointer := receiverTypeName(recvField.Type)
+	t, ok := m.exposedTypes[recvName]
+	if !ok {
+		// No expose-type directive for this receiver; nothing to generate.
+		return nil
+	}
+	if pointer {
+		t.Pointer = true
+	}
+
+	fn, err := m.newExportedFunc(decl)
+	if err != nil {
+		return fmt.Errorf("method %s.%s: %w", recvName, decl.Name.Name, err)
+	}
+	fn.FuncGenName = "gen" + recvName + decl.Name.Name
+	m.addImport("context")
+	t.Methods = append(t.Methods, *fn)
+	return nil
+}
+
+func receiverTypeName(expr ast.Expr) (name string, pointer bool) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		return star.X.(*ast.Ident).Name, true
+	}
+	return expr.(*ast.Ident).Name, false
+}
+
+// sortedExposedTypes returns the module's exposed types in a deterministic
+// order so that repeated generation produces a stable diff.
+func (m *Module) sortedExposedTypes() []*exposedType {
+	names := make([]string, 0, len(m.exposedTypes))
+	for name := range m.exposedTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	types := make([]*exposedType, len(names))
+	for i, name := range names {
+		types[i] = m.exposedTypes[name]
+	}
+	return types
+}
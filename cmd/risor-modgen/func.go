@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// Param describes a single parameter of an ExportedFunc, along with the
+// object package helpers needed to convert a Risor argument into the Go
+// value the underlying function expects.
+type Param struct {
+	Name string
+
+	// ReadFunc is the object.Read* helper used to pull a Go value out of
+	// the object.Object argument, e.g. "AsInt" or "AsString".
+	ReadFunc string
+
+	// CastFunc, when set, converts the value returned by ReadFunc into the
+	// exact Go type the function parameter expects, e.g. "int32".
+	CastFunc string
+
+	// CastMaxValue and CastMinValue optionally bound the raw value before
+	// CastFunc is applied, so that out-of-range values produce a Risor
+	// error instead of silently wrapping.
+	CastMaxValue string
+	CastMinValue string
+
+	// Variadic is true for a trailing `...T` parameter. The generated
+	// wrapper collects every remaining argument into a []GoElemType using
+	// the same ReadFunc/CastFunc as a normal parameter of that element type.
+	Variadic   bool
+	GoElemType string
+}
+
+// ReturnInfo describes how a Go function's return value is converted back
+// into an object.Object.
+type ReturnInfo struct {
+	// CastFunc converts the Go return value into the type NewFunc expects,
+	// e.g. "int64" before passing to object.NewInt.
+	CastFunc string
+
+	// NewFunc is the object.New* constructor used to wrap the (possibly
+	// cast) return value, e.g. "NewInt" or "NewString".
+	NewFunc string
+}
+
+// ExportedFunc describes a single Go function (or method) that modgen will
+// generate a Risor builtin wrapper for.
+type ExportedFunc struct {
+	// Pos is the source position of the FuncDecl this was parsed from, used
+	// to report lint diagnostics against the original Go file.
+	Pos token.Pos
+
+	// FuncName is the Go identifier for the function being wrapped.
+	FuncName string
+
+	// ExportedName is the name the builtin is registered under in Risor.
+	ExportedName string
+
+	// FuncGenName is the name of the generated wrapper function.
+	FuncGenName string
+
+	// Doc is the Go doc comment from the source FuncDecl, carried over so
+	// it can be rendered into the companion .d.risor stub.
+	Doc string
+
+	// ContextNotFirst is set when a context.Context parameter was found
+	// somewhere other than the first parameter; flagged by Lint.
+	ContextNotFirst bool
+
+	Params   []Param
+	Variadic bool // true when the last entry in Params is variadic
+	MinArgs  int  // minimum argument count; equals len(Params) unless Variadic
+
+	// Return holds the conversion info for a function with a single
+	// non-error result. Functions with more than one non-error result use
+	// Returns instead, and pack them per ReturnsMode.
+	Return       *ReturnInfo
+	Returns      []ReturnInfo
+	ReturnsMode  string   // "tuple", "list" or "map"; only set when len(Returns) > 1
+	ReturnKeys   []string // map keys, aligned with Returns, when ReturnsMode == "map"
+	ReturnsError bool
+	NeedsContext bool
+}
+
+// parseFuncDecl inspects a top-level function declaration and, if it is
+// exported and has no receiver, records it as an ExportedFunc to generate a
+// builtin wrapper for. Functions with a receiver are handled separately by
+// parseReceiverFunc.
+func (m *Module) parseFuncDecl(decl *ast.FuncDecl) error {
+	if decl.Recv != nil {
+		return m.parseReceiverFunc(decl)
+	}
+	if decl.Name.Name == "Module" {
+		m.hasExplicitModuleFunc = true
+		return nil
+	}
+	if !decl.Name.IsExported() {
+		return nil
+	}
+	fn, err := m.newExportedFunc(decl)
+	if err != nil {
+		return err
+	}
+	m.addImport("context")
+	m.exportedFuncs = append(m.exportedFuncs, *fn)
+	return nil
+}
+
+// newExportedFunc builds an ExportedFunc from a parsed *ast.FuncDecl,
+// inspecting its parameter and result types to pick the object.Read*/New*
+// conversion helpers used in the generated wrapper.
+func (m *Module) newExportedFunc(decl *ast.FuncDecl) (*ExportedFunc, error) {
+	name := decl.Name.Name
+	fn := &ExportedFunc{
+		Pos:          decl.Pos(),
+		FuncName:     name,
+		ExportedName: name,
+		FuncGenName:  "gen" + strings.ToUpper(name[:1]) + name[1:],
+		Doc:          strings.TrimSpace(decl.Doc.Text()),
+	}
+	returnsMode, returnKeys, err := m.parseFuncDirective(decl.Doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	fieldList := decl.Type.Params
+	if fieldList != nil {
+		for fieldIdx, field := range fieldList.List {
+			names := field.Names
+			if len(names) == 0 {
+				names = []*ast.Ident{{Name: "_"}}
+			}
+			for _, ident := range names {
+				typeExpr := field.Type
+				if m.sprintExpr(typeExpr) == "context.Context" {
+					fn.NeedsContext = true
+					if fieldIdx != 0 {
+						fn.ContextNotFirst = true
+					}
+					continue
+				}
+				if ellipsis, ok := typeExpr.(*ast.Ellipsis); ok {
+					if fieldIdx != len(fieldList.List)-1 {
+						return nil, fmt.Errorf("variadic parameter %q must be last", ident.Name)
+					}
+					param, err := m.paramFromType(ident.Name, ellipsis.Elt)
+					if err != nil {
+						return nil, err
+					}
+					param.Variadic = true
+					param.GoElemType = m.sprintExpr(ellipsis.Elt)
+					fn.Variadic = true
+					fn.Params = append(fn.Params, *param)
+					continue
+				}
+				param, err := m.paramFromType(ident.Name, typeExpr)
+				if err != nil {
+					return nil, err
+				}
+				fn.Params = append(fn.Params, *param)
+			}
+		}
+	}
+	fn.MinArgs = len(fn.Params)
+	if fn.Variadic {
+		fn.MinArgs--
+	}
+
+	if decl.Type.Results != nil {
+		results := decl.Type.Results.List
+		if len(results) > 0 {
+			last := results[len(results)-1]
+			if m.sprintExpr(last.Type) == "error" {
+				fn.ReturnsError = true
+				results = results[:len(results)-1]
+			}
+		}
+		for _, result := range results {
+			ret, err := m.returnFromType(result.Type)
+			if err != nil {
+				return nil, err
+			}
+			fn.Returns = append(fn.Returns, *ret)
+		}
+		switch len(fn.Returns) {
+		case 0:
+		case 1:
+			fn.Return = &fn.Returns[0]
+		default:
+			if returnsMode == "" {
+				returnsMode = "tuple"
+			}
+			fn.ReturnsMode = returnsMode
+			fn.ReturnKeys = returnKeys
+			if fn.ReturnsMode == "map" && len(fn.ReturnKeys) != len(fn.Returns) {
+				return nil, fmt.Errorf("returns=map(...) needs %d keys, got %d", len(fn.Returns), len(fn.ReturnKeys))
+			}
+		}
+	}
+	return fn, nil
+}
+
+// parseFuncDirective reads a `//risor:generate returns=tuple|list|map(a,b)`
+// comment attached to a function declaration, used to control how multiple
+// non-error return values are packed into an object.Object.
+func (m *Module) parseFuncDirective(doc *ast.CommentGroup) (mode string, keys []string, err error) {
+	if doc == nil {
+		return "", nil, nil
+	}
+	for _, comment := range doc.List {
+		after, ok := cutPrefixAndSpace(comment.Text, "//risor:generate")
+		if !ok {
+			continue
+		}
+		value, ok := strings.CutPrefix(after, "returns=")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid //risor:generate field: %q", after)
+		}
+		if rest, ok := strings.CutPrefix(value, "map("); ok {
+			rest = strings.TrimSuffix(rest, ")")
+			for _, key := range strings.Split(rest, ",") {
+				keys = append(keys, strings.TrimSpace(key))
+			}
+			return "map", keys, nil
+		}
+		switch value {
+		case "tuple", "list":
+			return value, nil, nil
+		default:
+			return "", nil, fmt.Errorf("invalid returns= mode: %q", value)
+		}
+	}
+	return "", nil, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+)
+
+// Diagnostic is a single actionable problem found by Lint, positioned
+// against the original Go source so it can be reported the way a compiler
+// error would be.
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// Lint walks the parsed module and reports every actionable problem it can
+// find, rather than stopping at the first one, so that a whole module can be
+// fixed in a single iteration.
+func (m *Module) Lint() []Diagnostic {
+	var diags []Diagnostic
+	seenNames := map[string]token.Pos{}
+
+	// Free functions are keyed by their builtin name; methods, which are
+	// dispatched through their type's proxy, by Type.Name.
+	check := func(fn ExportedFunc, context, key string) {
+		if fn.ContextNotFirst {
+			diags = append(diags, m.diagf(fn.Pos,
+				"%s: context.Context parameter must be first", context))
+		}
+		for _, p := range fn.Params {
+			if p.ReadFunc != "AsInt" && p.ReadFunc != "AsFloat" && (p.CastMinValue != "" || p.CastMaxValue != "") {
+				diags = append(diags, m.diagf(fn.Pos,
+					"%s: cast bounds on non-numeric parameter %q are never checked", context, p.Name))
+			}
+		}
+		if prev, ok := seenNames[key]; ok {
+			diags = append(diags, m.diagf(fn.Pos,
+				"%s: name %q collides with the one declared at %s", context, fn.ExportedName, m.fset.Position(prev)))
+		} else {
+			seenNames[key] = fn.Pos
+		}
+	}
+
+	for _, fn := range m.exportedFuncs {
+		check(fn, fmt.Sprintf("func %s", fn.FuncName), fn.ExportedName)
+	}
+	for _, t := range m.sortedExposedTypes() {
+		for _, fn := range t.Methods {
+			check(fn, fmt.Sprintf("method %s.%s", t.Name, fn.FuncName), t.Name+"."+fn.ExportedName)
+		}
+	}
+
+	if m.skipModulesFunc && m.hasExplicitModuleFunc {
+		diags = append(diags, Diagnostic{
+			Message: fmt.Sprintf("module %q: //risor:generate no-module-func is set, but a Module() func is also declared by hand", m.Name),
+		})
+	}
+
+	return diags
+}
+
+func (m *Module) diagf(pos token.Pos, format string, args ...any) Diagnostic {
+	return Diagnostic{Pos: m.fset.Position(pos), Message: fmt.Sprintf(format, args...)}
+}
@@ -49,23 +49,32 @@ func run(options Options) error {
 		return err
 	}
 	fmt.Println("Generating Risor module bindings")
+	var allDiags []Diagnostic
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
 		}
 		path := filepath.Join(options.Modules, entry.Name())
-		if err := runInDir(path, options); err != nil {
+		diags, err := runInDir(path, options)
+		if err != nil {
 			return fmt.Errorf("module %q: %w", path, err)
 		}
+		allDiags = append(allDiags, diags...)
+	}
+	if len(allDiags) > 0 {
+		for _, d := range allDiags {
+			fmt.Println(d.String())
+		}
+		return fmt.Errorf("%d lint diagnostic(s) found", len(allDiags))
 	}
 	return nil
 }
 
-func runInDir(dir string, options Options) error {
+func runInDir(dir string, options Options) ([]Diagnostic, error) {
 	fset := token.NewFileSet()
 	ignoreRegex, err := regexp.Compile(options.IgnoreFiles)
 	if err != nil {
-		return fmt.Errorf("parse -ignore flag: %w", err)
+		return nil, fmt.Errorf("parse -ignore flag: %w", err)
 	}
 	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
 		return !strings.HasSuffix(fi.Name(), "_gen.go") &&
@@ -74,7 +83,7 @@ func runInDir(dir string, options Options) error {
 			!ignoreRegex.MatchString(fi.Name())
 	}, parser.ParseComments|parser.DeclarationErrors)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(pkgs) != 1 {
@@ -82,13 +91,14 @@ func runInDir(dir string, options Options) error {
 		for name := range pkgs {
 			pkgNames = append(pkgNames, name)
 		}
-		return fmt.Errorf("directory must only contain 1 package, but got: %s", pkgNames)
+		return nil, fmt.Errorf("directory must only contain 1 package, but got: %s", pkgNames)
 	}
 
+	var diags []Diagnostic
 	for _, pkg := range pkgs {
 		mod, err := Parse(fset, pkg)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if !mod.HasGenerateComment {
@@ -96,11 +106,20 @@ func runInDir(dir string, options Options) error {
 			continue
 		}
 
+		if modDiags := mod.Lint(); len(modDiags) > 0 {
+			diags = append(diags, modDiags...)
+			continue
+		}
+
 		genFile := filepath.Join(dir, mod.Name+"_gen.go")
 
 		changed, written, err := mod.WriteFile(genFile, options)
 		if err != nil {
-			return fmt.Errorf("write generated file: %w", err)
+			return nil, fmt.Errorf("write generated file: %w", err)
+		}
+
+		if _, _, err := mod.WriteStubFile(dir, options); err != nil {
+			return nil, fmt.Errorf("write stub file: %w", err)
 		}
 
 		if !changed {
@@ -110,19 +129,22 @@ func runInDir(dir string, options Options) error {
 
 		fmt.Printf("Wrote to file: %s (%d B)\n", genFile, written)
 	}
-	return nil
+	return diags, nil
 }
 
 type Module struct {
 	Name string
 
-	HasGenerateComment bool
-	skipModulesFunc    bool
+	HasGenerateComment    bool
+	skipModulesFunc       bool
+	hasExplicitModuleFunc bool
 
 	fset             *token.FileSet
 	buildConstraints []string
 	exportedFuncs    []ExportedFunc
 	imports          []string
+	exposedTypes     map[string]*exposedType
+	converters       map[string]converter
 }
 
 func Parse(fset *token.FileSet, pkg *ast.Package) (*Module, error) {
@@ -130,8 +152,22 @@ func Parse(fset *token.FileSet, pkg *ast.Package) (*Module, error) {
 		Name: pkg.Name,
 		fset: fset,
 	}
+	// pkg.Files is a Go map, so iterating it gives files in an arbitrary
+	// order. A directive like //risor:converter or the type-level
+	// //risor:generate expose-type can live in a different file than the
+	// code that depends on it (a method on the exposed type, a parameter
+	// using the converter), so registering directives and consuming them
+	// can't happen in the same per-file pass: whichever file happened to
+	// be processed first would silently miss directives declared later.
+	// Two passes over all files first, in map order, makes that order
+	// stop mattering.
 	for path, file := range pkg.Files {
-		if err := mod.parseFile(file); err != nil {
+		if err := mod.parseFileDirectives(file); err != nil {
+			return nil, fmt.Errorf("file %q: %w", path, err)
+		}
+	}
+	for path, file := range pkg.Files {
+		if err := mod.parseFileDecls(file); err != nil {
 			return nil, fmt.Errorf("file %q: %w", path, err)
 		}
 	}
@@ -219,10 +255,30 @@ import (
 // {{ .FuncGenName }} is a wrapper function around [{{ .FuncName }}]
 // that implements [object.BuiltinFunction].
 func {{ .FuncGenName }}(ctx context.Context, args ...object.Object) object.Object {
+	{{- if .Variadic }}
+	if len(args) < {{ .MinArgs }} {
+		return object.NewArgsError("{{ $.Package }}.{{ .ExportedName }}", {{ .MinArgs }}, len(args))
+	}
+	{{- else }}
 	if len(args) != {{ len .Params }} {
 		return object.NewArgsError("{{ $.Package }}.{{ .ExportedName }}", {{ len .Params }}, len(args))
 	}
+	{{- end }}
 	{{- range $index, $param := .Params }}
+	{{- if .Variadic }}
+	{{ .Name }}Param := make([]{{ .GoElemType }}, len(args)-{{ $index }})
+	for i := {{ $index }}; i < len(args); i++ {
+		{{- if .ReadFunc }}
+		elem{{ if .CastFunc }}Raw{{ end }}, err := object.{{ .ReadFunc }}(args[i])
+		if err != nil {
+			return err
+		}
+		{{- else }}
+		elem{{ if .CastFunc }}Raw{{ end }} := args[i]
+		{{- end }}
+		{{ .Name }}Param[i-{{ $index }}] = {{ if .CastFunc }}{{ .CastFunc }}(elemRaw){{ else }}elem{{ end }}
+	}
+	{{- else }}
 	{{- if .ReadFunc }}
 	{{ .Name }}Param{{ if .CastFunc }}Raw{{ end }}, err := object.{{ .ReadFunc }}(args[{{ $index }}])
 	if err != nil {
@@ -245,9 +301,10 @@ func {{ .FuncGenName }}(ctx context.Context, args ...object.Object) object.Objec
 	{{ .Name }}Param := {{ .CastFunc }}({{ .Name }}ParamRaw)
 	{{- end }}
 	{{- end }}
-	{{- if or .Return .ReturnsError }}
-	{{ if .Return }}result{{ end -}}
-	{{- if and .Return .ReturnsError }}, {{ end -}}
+	{{- end }}
+	{{- if or .Returns .ReturnsError }}
+	{{- range $i, $r := .Returns }}{{ if gt $i 0 }}, {{ end }}result{{ if gt (len $func.Returns) 1 }}{{ $i }}{{ end }}{{ end -}}
+	{{- if and .Returns .ReturnsError }}, {{ end -}}
 	{{ if .ReturnsError }}resultErr{{ end }} := {{ end -}}
 	{{ .FuncName }}(
 		{{- if .NeedsContext -}}
@@ -255,7 +312,7 @@ func {{ .FuncGenName }}(ctx context.Context, args ...object.Object) object.Objec
 		{{- end -}}
 		{{- range $index, $param := .Params -}}
 			{{- if gt $index 0}}, {{ end -}}
-			{{.Name}}Param
+			{{.Name}}Param{{ if .Variadic }}...{{ end }}
 		{{- end -}}
 	)
 	{{- if .ReturnsError }}
@@ -263,7 +320,19 @@ func {{ .FuncGenName }}(ctx context.Context, args ...object.Object) object.Objec
 		return object.NewError(resultErr)
 	}
 	{{- end }}
-	{{- if .Return }}
+	{{- if gt (len .Returns) 1 }}
+	return object.{{ if eq .ReturnsMode "map" }}NewMap(map[string]object.Object{
+		{{- range $i, $r := .Returns }}
+		"{{ index $func.ReturnKeys $i }}": {{ with $r.NewFunc }}object.{{ . }}({{ end }}{{ with $r.CastFunc }}{{ . }}(result{{ $i }}){{ else }}result{{ $i }}{{ end }}{{ if $r.NewFunc }}){{ end }},
+		{{- end }}
+	})
+	{{- else }}NewList([]object.Object{
+		{{- range $i, $r := .Returns }}
+		{{ with $r.NewFunc }}object.{{ . }}({{ end }}{{ with $r.CastFunc }}{{ . }}(result{{ $i }}){{ else }}result{{ $i }}{{ end }}{{ if $r.NewFunc }}){{ end }},
+		{{- end }}
+	})
+	{{- end }}
+	{{- else if .Return }}
 	return {{ with .Return.NewFunc -}}object.{{ . }}({{ end }}
 		{{- with .Return.CastFunc -}}
 			{{ . }}(result)
@@ -278,6 +347,78 @@ func {{ .FuncGenName }}(ctx context.Context, args ...object.Object) object.Objec
 {{- end }}
 {{- end }}
 
+{{- range $type := .ExposedTypes }}
+
+// {{ $type.ProxyName }} wraps a {{ if $type.Pointer }}*{{ end }}{{ $type.Name }} so that
+// it can be passed around as a Risor object.Object, with its methods exposed
+// as builtins returned by GetAttr.
+type {{ $type.ProxyName }} struct {
+	*object.BaseObject
+	value {{ if $type.Pointer }}*{{ end }}{{ $type.Name }}
+}
+
+// {{ $type.ConstructorName }} wraps a {{ if $type.Pointer }}*{{ end }}{{ $type.Name }} in a {{ $type.ProxyName }}.
+func {{ $type.ConstructorName }}(value {{ if $type.Pointer }}*{{ end }}{{ $type.Name }}) object.Object {
+	return &{{ $type.ProxyName }}{value: value}
+}
+
+// Type returns the object type of this proxy.
+func (p *{{ $type.ProxyName }}) Type() object.Type {
+	return "{{ $.Package }}.{{ $type.Name }}"
+}
+
+// GetAttr returns a builtin bound to this proxy's receiver for the given
+// method name.
+func (p *{{ $type.ProxyName }}) GetAttr(name string) (object.Object, bool) {
+	switch name {
+	{{- range $func := $type.Methods }}
+	case "{{ $func.ExportedName }}":
+		return object.NewBuiltin("{{ $.Package }}.{{ $type.Name }}.{{ $func.ExportedName }}", func(ctx context.Context, args ...object.Object) object.Object {
+			return {{ $func.FuncGenName }}(ctx, p.value, args...)
+		}), true
+	{{- end }}
+	default:
+		return nil, false
+	}
+}
+{{- range $func := $type.Methods }}
+
+// {{ $func.FuncGenName }} is a wrapper function around [{{ $type.Name }}.{{ $func.FuncName }}]
+// that implements [object.BuiltinFunction] bound to an existing receiver.
+func {{ $func.FuncGenName }}(ctx context.Context, recv {{ if $type.Pointer }}*{{ end }}{{ $type.Name }}, args ...object.Object) object.Object {
+	if len(args) != {{ len $func.Params }} {
+		return object.NewArgsError("{{ $.Package }}.{{ $type.Name }}.{{ $func.ExportedName }}", {{ len $func.Params }}, len(args))
+	}
+	{{- range $index, $param := $func.Params }}
+	{{ .Name }}Param, err := object.{{ .ReadFunc }}(args[{{ $index }}])
+	if err != nil {
+		return err
+	}
+	{{- end }}
+	{{- if or $func.Return $func.ReturnsError }}
+	{{ if $func.Return }}result{{ end -}}
+	{{- if and $func.Return $func.ReturnsError }}, {{ end -}}
+	{{ if $func.ReturnsError }}resultErr{{ end }} := {{ end -}}
+	recv.{{ $func.FuncName }}(
+		{{- range $index, $param := $func.Params -}}
+			{{- if gt $index 0}}, {{ end -}}
+			{{.Name}}Param
+		{{- end -}}
+	)
+	{{- if $func.ReturnsError }}
+	if resultErr != nil {
+		return object.NewError(resultErr)
+	}
+	{{- end }}
+	{{- if $func.Return }}
+	return object.{{ $func.Return.NewFunc }}(result)
+	{{- else }}
+	return object.Nil
+	{{- end }}
+}
+{{- end }}
+{{- end }}
+
 // addGeneratedBuiltins adds the generated builtin wrappers to the given map.
 //
 // Useful if you want to write your own "Module()" function.
@@ -312,16 +453,24 @@ func (m *Module) Fprint(w io.Writer, options Options) {
 		BuildConstraints []string
 		SkipModulesFunc  bool
 		ExportedFuncs    []ExportedFunc
+		ExposedTypes     []*exposedType
 	}{
 		Package:          m.Name,
 		Imports:          m.imports,
 		BuildConstraints: m.buildConstraints,
 		SkipModulesFunc:  m.skipModulesFunc,
 		ExportedFuncs:    m.exportedFuncs,
+		ExposedTypes:     m.sortedExposedTypes(),
 	})
 }
 
-func (m *Module) parseFile(file *ast.File) error {
+// parseFileDirectives registers everything a file can declare that some
+// other file's declarations might depend on: the //risor:generate comment
+// and any build constraints it gates, //risor:converter directives, and
+// //risor:generate expose-type directives on type declarations. Must run,
+// for every file in the package, before parseFileDecls processes any of
+// them.
+func (m *Module) parseFileDirectives(file *ast.File) error {
 	fileHasGenerateComment, err := m.parseGenerateComment(file)
 	if err != nil {
 		return err
@@ -334,14 +483,33 @@ func (m *Module) parseFile(file *ast.File) error {
 		}
 	}
 
+	if err := m.parseConverterDirective(file); err != nil {
+		return err
+	}
+
 	for _, decl := range file.Decls {
-		switch decl := decl.(type) {
-		case *ast.FuncDecl:
-			if err := m.parseFuncDecl(decl); err != nil {
-				return fmt.Errorf("func %s: %w", decl.Name.Name, err)
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			if err := m.parseGenDecl(genDecl); err != nil {
+				return fmt.Errorf("decl: %w", err)
 			}
-		case *ast.BadDecl:
-		case *ast.GenDecl:
+		}
+	}
+	return nil
+}
+
+// parseFileDecls processes a file's function declarations: free functions
+// and methods on exposed types. Run only after parseFileDirectives has
+// registered every file's directives, so a method's receiver type is
+// already known to be exposed (or not) regardless of which file declared
+// the expose-type directive for it.
+func (m *Module) parseFileDecls(file *ast.File) error {
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if err := m.parseFuncDecl(funcDecl); err != nil {
+			return fmt.Errorf("func %s: %w", funcDecl.Name.Name, err)
 		}
 	}
 	return nil
@@ -413,4 +581,4 @@ func cutPrefixAndSpace(s, prefix string) (after string, ok bool) {
 		return s, false
 	}
 	return after[1:], true
-}
\ No newline at end of file
+}
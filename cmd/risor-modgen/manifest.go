@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+// risorType maps the object.Read* helper used for a parameter or return
+// value to the Risor type name an editor or the REPL's help() should show.
+// Unrecognized or absent ReadFuncs fall back to "any".
+func risorType(readFunc string) string {
+	switch readFunc {
+	case "AsString":
+		return "string"
+	case "AsInt":
+		return "int"
+	case "AsFloat":
+		return "float"
+	case "AsBool":
+		return "bool"
+	case "AsBytes":
+		return "byte_slice"
+	case "AsMap":
+		return "map"
+	case "AsReader", "AsWriter":
+		return "object"
+	default:
+		return "any"
+	}
+}
+
+// WriteStubFile emits a companion "<pkg>.d.risor" manifest describing every
+// generated builtin: its Risor name, parameter names/types, return type,
+// and the Go doc comment carried over from the source FuncDecl. Tools like
+// an LSP server, docs generator, or the REPL's help() can read this instead
+// of re-parsing the Go source.
+//
+// The manifest uses a small declaration syntax rather than full Risor
+// syntax, since these functions have no body:
+//
+//	// Doc comment, copied verbatim from the Go source.
+//	func Name(param string, other int) string
+func (m *Module) WriteStubFile(dir string, options Options) (bool, int, error) {
+	var buf bytes.Buffer
+	m.fprintStub(&buf)
+	path := filepath.Join(dir, m.Name+".d.risor")
+	return writeFileCheckChanged(path, buf.Bytes())
+}
+
+func (m *Module) fprintStub(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "// Code generated by risor-modgen. DO NOT EDIT.\n")
+	fmt.Fprintf(buf, "module %s\n", m.Name)
+	for _, fn := range m.exportedFuncs {
+		buf.WriteString("\n")
+		for _, line := range splitLines(fn.Doc) {
+			fmt.Fprintf(buf, "// %s\n", line)
+		}
+		fmt.Fprintf(buf, "func %s(%s)", fn.ExportedName, stubParams(fn.Params))
+		if fn.Return != nil {
+			fmt.Fprintf(buf, " %s", risorTypeForReturn(fn.Return.NewFunc))
+		} else if len(fn.Returns) > 1 {
+			fmt.Fprintf(buf, " (%s)", stubReturns(fn.Returns))
+		}
+		buf.WriteString("\n")
+	}
+	for _, t := range m.sortedExposedTypes() {
+		buf.WriteString("\n")
+		fmt.Fprintf(buf, "type %s\n", t.Name)
+		for _, fn := range t.Methods {
+			for _, line := range splitLines(fn.Doc) {
+				fmt.Fprintf(buf, "  // %s\n", line)
+			}
+			fmt.Fprintf(buf, "  func %s.%s(%s)", t.Name, fn.ExportedName, stubParams(fn.Params))
+			if fn.Return != nil {
+				fmt.Fprintf(buf, " %s", risorTypeForReturn(fn.Return.NewFunc))
+			}
+			buf.WriteString("\n")
+		}
+	}
+}
+
+func stubParams(params []Param) string {
+	var buf bytes.Buffer
+	for i, p := range params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%s %s", p.Name, risorType(p.ReadFunc))
+		if p.Variadic {
+			buf.WriteString("...")
+		}
+	}
+	return buf.String()
+}
+
+// risorTypeForReturn maps the object.New* constructor used for a return
+// value to the Risor type name shown in the stub file.
+func risorTypeForReturn(newFunc string) string {
+	switch newFunc {
+	case "NewString":
+		return "string"
+	case "NewInt":
+		return "int"
+	case "NewFloat":
+		return "float"
+	case "NewBool":
+		return "bool"
+	case "NewByteSlice":
+		return "byte_slice"
+	case "NewMap":
+		return "map"
+	default:
+		return "any"
+	}
+}
+
+func stubReturns(returns []ReturnInfo) string {
+	var buf bytes.Buffer
+	for i, r := range returns {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(risorTypeForReturn(r.NewFunc))
+	}
+	return buf.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var lines []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		lines = append(lines, s[start:])
+	}
+	return lines
+}
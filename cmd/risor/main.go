@@ -0,0 +1,108 @@
+// Command risor runs Tamarin scripts, and doubles as a small bytecode
+// toolchain for precompiling them.
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"os"
+
+	"github.com/cloudcmds/tamarin/internal/compiler"
+	"github.com/cloudcmds/tamarin/internal/vm"
+	"github.com/cloudcmds/tamarin/parser"
+)
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "usage: risor <run|compile|run-compiled> <file> [out]")
+		os.Exit(1)
+	}
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = run(os.Args[2])
+	case "compile":
+		err = compileFile(os.Args[2:])
+	case "run-compiled":
+		err = runCompiled(os.Args[2])
+	default:
+		err = fmt.Errorf("unknown command: %s", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "risor:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses, compiles, and immediately executes the script at path.
+func run(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// fset is passed into Parse, not built separately, so that it actually
+	// has path registered (via fset.AddFile) under the token.Pos values
+	// the parser hands back — otherwise FileSet.Position on those
+	// positions would resolve to a zero/invalid Position, and every
+	// file:line:col error this program relies on compiler.Options.FileSet
+	// for would come back empty.
+	fset := token.NewFileSet()
+	program, err := parser.Parse(fset, path, string(src))
+	if err != nil {
+		return err
+	}
+	c := compiler.New(compiler.Options{Name: "main", FileSet: fset})
+	scope, err := c.Compile(program)
+	if err != nil {
+		return err
+	}
+	return vm.New(scope).Run(context.Background())
+}
+
+// compileFile compiles the script at args[0] and writes its bytecode to
+// args[1], defaulting to args[0] with a ".trc" suffix appended. The
+// resulting file can later be run with "risor run-compiled" alone,
+// without the original source or a parser.
+func compileFile(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: risor compile <script> [out]")
+	}
+	src, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	out := args[0] + ".trc"
+	if len(args) > 1 {
+		out = args[1]
+	}
+	fset := token.NewFileSet()
+	program, err := parser.Parse(fset, args[0], string(src))
+	if err != nil {
+		return err
+	}
+	c := compiler.New(compiler.Options{Name: "main", FileSet: fset})
+	scope, err := c.Compile(program)
+	if err != nil {
+		return err
+	}
+	data, err := compiler.Marshal(scope)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, data, 0o644)
+}
+
+// runCompiled loads bytecode previously produced by "risor compile" and
+// runs it directly, without parsing or compiling anything.
+func runCompiled(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	scope, err := compiler.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	return vm.New(scope).Run(context.Background())
+}
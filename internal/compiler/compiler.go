@@ -2,6 +2,8 @@ package compiler
 
 import (
 	"fmt"
+	"go/token"
+	"io"
 
 	"github.com/cloudcmds/tamarin/ast"
 	"github.com/cloudcmds/tamarin/internal/op"
@@ -13,12 +15,83 @@ type Compiler struct {
 	main     *Scope
 	current  *Scope
 	startPos int
+
+	opts Options
+
+	// compiledModules caches compiled script modules by resolved import
+	// path, so that importing the same module twice doesn't recompile
+	// it (a cycle is rejected via importStack before this is consulted).
+	// Shared with every child Compiler spawned to
+	// compile a nested import.
+	compiledModules map[string]*object.CompiledFunction
+
+	// importStack holds the resolved path of every import currently being
+	// compiled, innermost last, so that a cycle can be detected and
+	// reported with the full chain.
+	importStack []string
+
+	// fset resolves token.Pos values recorded in a Scope's SourceMap back
+	// to file:line:col, for CompilerError and runtime error reporting.
+	fset *token.FileSet
+
+	// nodes tracks the AST node currently being compiled, pushed and
+	// popped around each compile() call, so emit() can record its
+	// position. Mirrors the "nodes []ast.Node" stack used by expr.
+	nodes []ast.Node
+
+	// errNode is the node compile() was working on when it first produced
+	// an error, captured before its defer pops nodes off c.nodes as the
+	// recursion unwinds. By the time Compile() calls wrapError, c.nodes is
+	// always empty again, so this is the only way wrapError can still
+	// attach a location to the error.
+	errNode ast.Node
+
+	// lastOptimizeStats records the instruction counts from the most
+	// recent optimizer pass, reported back via OptimizeStats.
+	lastOptimizeStats OptimizeStats
+
+	// indent tracks the current nesting depth for Options.Trace output.
+	indent int
+}
+
+// ModuleGetter resolves an imported module name to either a Go-native
+// object.Object (a builtin module registered by the host) or to the source
+// of a script module to compile. Exactly one of the two return values other
+// than ok should be used: if isBuiltin is true, obj is the module object to
+// bind; otherwise source holds the script to compile.
+type ModuleGetter interface {
+	GetModule(name string) (obj object.Object, source []byte, isBuiltin bool, ok bool)
 }
 
 type Options struct {
 	Builtins map[string]object.Object
 	Name     string
 	Scope    *Scope
+
+	// ModuleGetter resolves `import` statements to builtin modules or
+	// script source. If nil, only AllowFileImport (when set) is consulted.
+	ModuleGetter ModuleGetter
+
+	// AllowFileImport enables resolving an import name to a file on disk,
+	// under ImportDir, trying each of ImportFileExt in turn.
+	AllowFileImport bool
+	ImportDir       string
+	ImportFileExt   []string
+
+	// FileSet resolves source positions recorded during compilation back
+	// to file:line:col. Required to get positions in CompilerError and
+	// runtime errors; if nil, positions are tracked as token.Pos only.
+	FileSet *token.FileSet
+
+	// DisableOptimizer turns off the peephole optimizer pass that Compile
+	// otherwise runs by default over every scope it produces.
+	DisableOptimizer bool
+
+	// Trace, when non-nil, receives a nested, indented log of every AST
+	// node entered, opcode emitted, scope pushed/popped, and symbol
+	// resolved during compilation. Mirrors Tengo's compiler trace; pass a
+	// bytes.Buffer to capture it for diffing across versions in a test.
+	Trace io.Writer
 }
 
 type Loop struct {
@@ -33,12 +106,20 @@ func New(opts Options) *Compiler {
 	} else {
 		main = &Scope{Name: opts.Name, Symbols: symbol.NewTable()}
 	}
+	main.FileSet = opts.FileSet
 	for name, builtin := range opts.Builtins {
 		if _, err := main.Symbols.InsertBuiltin(name, builtin); err != nil {
 			panic(fmt.Sprintf("failed to insert builtin %s: %s", name, err))
 		}
 	}
-	return &Compiler{main: main, current: main, startPos: len(main.Instructions)}
+	return &Compiler{
+		main:            main,
+		current:         main,
+		startPos:        len(main.Instructions),
+		opts:            opts,
+		compiledModules: map[string]*object.CompiledFunction{},
+		fset:            opts.FileSet,
+	}
 }
 
 func (c *Compiler) CurrentScope() *Scope {
@@ -58,12 +139,33 @@ func (c *Compiler) NewInstructions() []op.Code {
 
 func (c *Compiler) Compile(node ast.Node) (*Scope, error) {
 	if err := c.compile(node); err != nil {
-		return nil, err
+		return nil, c.wrapError(err)
+	}
+	if !c.opts.DisableOptimizer {
+		c.lastOptimizeStats = optimize(c.main)
 	}
 	return c.main, nil
 }
 
-func (c *Compiler) compile(node ast.Node) error {
+// OptimizeStats reports the instruction-slot counts, across the whole
+// scope tree, from before and after the most recent optimizer pass. Like
+// NewInstructions, it's a simple introspection hook rather than part of
+// compilation itself; it returns the zero value if DisableOptimizer was
+// set.
+func (c *Compiler) OptimizeStats() OptimizeStats {
+	return c.lastOptimizeStats
+}
+
+func (c *Compiler) compile(node ast.Node) (err error) {
+	c.nodes = append(c.nodes, node)
+	defer func() {
+		if err != nil && c.errNode == nil {
+			c.errNode = node
+		}
+		c.nodes = c.nodes[:len(c.nodes)-1]
+	}()
+	c.traceEnter(node)
+	defer c.traceExit()
 	scope := c.CurrentScope()
 	switch node := node.(type) {
 	case *ast.Nil:
@@ -128,6 +230,7 @@ func (c *Compiler) compile(node ast.Node) error {
 		if !found {
 			return fmt.Errorf("undefined variable: %s", name)
 		}
+		c.traceSymbol(name, sym.Scope, sym.Symbol.Index)
 		switch sym.Scope {
 		case symbol.ScopeGlobal:
 			c.emit(op.LoadGlobal, sym.Symbol.Index)
@@ -194,6 +297,10 @@ func (c *Compiler) compile(node ast.Node) error {
 		if err := c.compilePostfix(node); err != nil {
 			return err
 		}
+	case *ast.Import:
+		if err := c.compileImport(node); err != nil {
+			return err
+		}
 	default:
 		panic(fmt.Sprintf("unknown ast node type: %T", node))
 	}
@@ -253,6 +360,12 @@ func (c *Compiler) compileConst(node *ast.Const) error {
 	if err := c.compile(expr); err != nil {
 		return err
 	}
+	if value, ok := c.foldConstBool(expr); ok {
+		if c.current.ConstBools == nil {
+			c.current.ConstBools = map[string]bool{}
+		}
+		c.current.ConstBools[name] = value
+	}
 	sym, err := c.current.Symbols.InsertVariable(name)
 	if err != nil {
 		return err
@@ -385,6 +498,7 @@ func (c *Compiler) compileFunc(node *ast.Func) error {
 	}
 	c.current.Children = append(c.current.Children, funcScope)
 	c.current = funcScope
+	c.traceScopePush(funcScope)
 
 	paramsIdx := map[string]int{}
 	paramsAst := node.Parameters()
@@ -419,6 +533,7 @@ func (c *Compiler) compileFunc(node *ast.Func) error {
 	} else if _, ok := statements[len(statements)-1].(*ast.Control); !ok {
 		c.emit(op.ReturnValue, 1)
 	}
+	c.traceScopePop(funcScope)
 	c.current = c.current.Parent
 	freeSymbols := funcScope.Symbols.Free()
 	fn := object.NewCompiledFunction(name, params, defaults, funcScope.Instructions, funcScope)
@@ -593,6 +708,19 @@ func (c *Compiler) compileSimpleFor(node *ast.For) error {
 }
 
 func (c *Compiler) compileIf(node *ast.If) error {
+	if value, ok := c.foldConstBool(node.Condition()); ok {
+		// The condition is known at compile time, so only the taken
+		// branch is compiled; the other branch's instructions and
+		// constants never make it into the scope, and no condition or
+		// jump is emitted at all.
+		if value {
+			return c.compile(node.Consequence())
+		}
+		if alternative := node.Alternative(); alternative != nil {
+			return c.compile(alternative)
+		}
+		return nil
+	}
 	if err := c.compile(node.Condition()); err != nil {
 		return err
 	}
@@ -674,11 +802,41 @@ func (c *Compiler) compileInfix(node *ast.Infix) error {
 	return nil
 }
 
+// constantKey returns the canonical Go value used to deduplicate obj in the
+// constant pool, and whether obj is hashable enough to dedupe at all.
+func constantKey(obj object.Object) (key any, hashable bool) {
+	switch obj := obj.(type) {
+	case *object.Int:
+		return obj.Value(), true
+	case *object.Float:
+		return obj.Value(), true
+	case *object.String:
+		return obj.Value(), true
+	case *object.Bool:
+		return obj.Value(), true
+	default:
+		return nil, false
+	}
+}
+
 func (c *Compiler) constant(obj object.Object) uint16 {
 	scope := c.current
-	scope.Constants = append(scope.Constants, obj)
+	key, hashable := constantKey(obj)
+	if hashable {
+		if scope.constantsIndex == nil {
+			scope.constantsIndex = map[any]uint16{}
+		}
+		if idx, ok := scope.constantsIndex[key]; ok {
+			return idx
+		}
+	}
 	// TODO: error if > 65535
-	return uint16(len(scope.Constants) - 1)
+	idx := uint16(len(scope.Constants))
+	scope.Constants = append(scope.Constants, obj)
+	if hashable {
+		scope.constantsIndex[key] = idx
+	}
+	return idx
 }
 
 func (c *Compiler) emit(opcode op.Code, operands ...uint16) uint16 {
@@ -689,6 +847,13 @@ func (c *Compiler) emit(opcode op.Code, operands ...uint16) uint16 {
 	scope := c.CurrentScope()
 	pos := len(scope.Instructions)
 	scope.Instructions = append(scope.Instructions, inst...)
+	if len(c.nodes) > 0 {
+		if scope.SourceMap == nil {
+			scope.SourceMap = map[int]token.Pos{}
+		}
+		scope.SourceMap[pos] = c.nodes[len(c.nodes)-1].Pos()
+	}
+	c.traceEmit(opcode, operands, uint16(pos))
 	return uint16(pos)
 }
 
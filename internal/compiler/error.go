@@ -0,0 +1,44 @@
+package compiler
+
+import (
+	"fmt"
+	"go/token"
+
+	"github.com/cloudcmds/tamarin/ast"
+)
+
+// CompilerError wraps an error encountered while compiling a specific AST
+// node, carrying enough to format a "file:line:col" location the way a Go
+// compile error does.
+type CompilerError struct {
+	FileSet *token.FileSet
+	Node    ast.Node
+	Err     error
+}
+
+func (e *CompilerError) Error() string {
+	if e.FileSet == nil || e.Node == nil {
+		return fmt.Sprintf("Compile Error: %s", e.Err)
+	}
+	pos := e.FileSet.Position(e.Node.Pos())
+	return fmt.Sprintf("Compile Error: %s at %s:%d:%d", e.Err, pos.Filename, pos.Line, pos.Column)
+}
+
+func (e *CompilerError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError attaches the node that was being compiled when err was first
+// produced (if any) to err, so that callers of Compile see a
+// file:line:col location instead of a bare message. c.nodes itself is
+// always empty by the time this runs, since every compile() frame pops
+// its entry on the way out, error or not; errNode is captured earlier,
+// before that unwinding, for exactly this reason.
+func (c *Compiler) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	node := c.errNode
+	c.errNode = nil
+	return &CompilerError{FileSet: c.fset, Node: node, Err: err}
+}
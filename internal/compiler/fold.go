@@ -0,0 +1,48 @@
+package compiler
+
+import "github.com/cloudcmds/tamarin/ast"
+
+// foldConstBool attempts to reduce expr to a compile-time-known boolean
+// value. It recognizes bool literals, `!` applied to a foldable expression,
+// `&&`/`||` infix expressions whose operands are both foldable, and
+// identifiers bound by a `const` declaration to a foldable value. Anything
+// else, including comparisons and calls, reports ok=false since evaluating
+// it may require runtime state or have side effects.
+func (c *Compiler) foldConstBool(expr ast.Node) (value bool, ok bool) {
+	switch expr := expr.(type) {
+	case *ast.Bool:
+		return expr.Value(), true
+	case *ast.Ident:
+		for scope := c.current; scope != nil; scope = scope.Parent {
+			if v, found := scope.ConstBools[expr.Literal()]; found {
+				return v, true
+			}
+		}
+		return false, false
+	case *ast.Prefix:
+		if expr.Operator() != "!" {
+			return false, false
+		}
+		v, ok := c.foldConstBool(expr.Right())
+		if !ok {
+			return false, false
+		}
+		return !v, true
+	case *ast.Infix:
+		left, ok := c.foldConstBool(expr.Left())
+		if !ok {
+			return false, false
+		}
+		right, ok := c.foldConstBool(expr.Right())
+		if !ok {
+			return false, false
+		}
+		switch expr.Operator() {
+		case "&&":
+			return left && right, true
+		case "||":
+			return left || right, true
+		}
+	}
+	return false, false
+}
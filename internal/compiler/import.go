@@ -0,0 +1,147 @@
+package compiler
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudcmds/tamarin/ast"
+	"github.com/cloudcmds/tamarin/internal/op"
+	"github.com/cloudcmds/tamarin/internal/symbol"
+	"github.com/cloudcmds/tamarin/object"
+	"github.com/cloudcmds/tamarin/parser"
+)
+
+// compileImport handles an `import` statement. The imported name is
+// resolved, in order, to:
+//
+//  1. a builtin module supplied by the host's Options.ModuleGetter
+//  2. a script module supplied as source by Options.ModuleGetter
+//  3. a file on disk under Options.ImportDir, when Options.AllowFileImport
+//
+// A builtin module is bound directly. A script module is compiled with a
+// child Compiler in a fresh scope (inheriting only builtin symbols, not the
+// parent's globals), cached by resolved path, and then invoked once with
+// its result bound to the local name.
+func (c *Compiler) compileImport(node *ast.Import) error {
+	name := node.Module()
+
+	if getter := c.opts.ModuleGetter; getter != nil {
+		if obj, source, isBuiltin, ok := getter.GetModule(name); ok {
+			if isBuiltin {
+				return c.bindImportedValue(name, obj)
+			}
+			return c.compileScriptImport(name, name, source)
+		}
+	}
+
+	if c.opts.AllowFileImport {
+		path, source, err := c.readImportFile(name)
+		if err != nil {
+			return err
+		}
+		if source != nil {
+			return c.compileScriptImport(name, path, source)
+		}
+	}
+
+	return fmt.Errorf("compile error: module not found: %s", name)
+}
+
+func (c *Compiler) readImportFile(name string) (path string, source []byte, err error) {
+	exts := c.opts.ImportFileExt
+	if len(exts) == 0 {
+		exts = []string{".tm"}
+	}
+	for _, ext := range exts {
+		candidate := filepath.Join(c.opts.ImportDir, name+ext)
+		data, readErr := os.ReadFile(candidate)
+		if readErr == nil {
+			return candidate, data, nil
+		}
+	}
+	return "", nil, nil
+}
+
+func (c *Compiler) bindImportedValue(name string, value object.Object) error {
+	sym, err := c.current.Symbols.InsertVariable(name)
+	if err != nil {
+		return err
+	}
+	c.emit(op.LoadConst, c.constant(value))
+	if c.current.Parent == nil {
+		c.emit(op.StoreGlobal, sym.Index)
+	} else {
+		c.emit(op.StoreFast, sym.Index)
+	}
+	return nil
+}
+
+// compileScriptImport compiles the given module source with a child
+// Compiler, caching the result by resolvedPath so that importing the same
+// module twice reuses the first compilation. An import cycle is rejected
+// with an error naming the whole chain.
+func (c *Compiler) compileScriptImport(name, resolvedPath string, source []byte) error {
+	for _, inProgress := range c.importStack {
+		if inProgress == resolvedPath {
+			chain := append(append([]string{}, c.importStack...), resolvedPath)
+			return fmt.Errorf("compile error: import cycle detected: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	fn, ok := c.compiledModules[resolvedPath]
+	if !ok {
+		// Parse against the importing compiler's own FileSet, when it has
+		// one, so the imported module's positions resolve against the
+		// same FileSet its childScope.FileSet (below) is about to be set
+		// to. A nil fset is never passed to Parse itself (parser.Parse
+		// registers resolvedPath into it via fset.AddFile); when
+		// c.opts.FileSet is nil, a throwaway one is used only so parsing
+		// succeeds, matching the no-Options.FileSet case elsewhere where
+		// position reporting is simply unavailable.
+		fset := c.opts.FileSet
+		if fset == nil {
+			fset = token.NewFileSet()
+		}
+		moduleAst, err := parser.Parse(fset, resolvedPath, string(source))
+		if err != nil {
+			return fmt.Errorf("compile error: failed to parse module %q: %w", name, err)
+		}
+
+		childScope := &Scope{Name: name, Symbols: symbol.NewTable(), FileSet: c.opts.FileSet}
+		child := &Compiler{
+			main:            childScope,
+			current:         childScope,
+			opts:            c.opts,
+			compiledModules: c.compiledModules,
+			importStack:     append(append([]string{}, c.importStack...), resolvedPath),
+			fset:            c.opts.FileSet,
+		}
+		for builtinName, builtin := range c.opts.Builtins {
+			if _, err := childScope.Symbols.InsertBuiltin(builtinName, builtin); err != nil {
+				return fmt.Errorf("compile error: failed to insert builtin %s: %w", builtinName, err)
+			}
+		}
+		if _, err := child.Compile(moduleAst); err != nil {
+			return fmt.Errorf("compile error: module %q: %w", name, err)
+		}
+
+		fn = object.NewCompiledFunction(name, nil, nil, childScope.Instructions, childScope)
+		c.compiledModules[resolvedPath] = fn
+	}
+
+	sym, err := c.current.Symbols.InsertVariable(name)
+	if err != nil {
+		return err
+	}
+	c.emit(op.LoadConst, c.constant(fn))
+	c.emit(op.Call, 0)
+	if c.current.Parent == nil {
+		c.emit(op.StoreGlobal, sym.Index)
+	} else {
+		c.emit(op.StoreFast, sym.Index)
+	}
+	return nil
+}
@@ -0,0 +1,629 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"go/token"
+	"io"
+
+	"github.com/cloudcmds/tamarin/internal/op"
+	"github.com/cloudcmds/tamarin/internal/symbol"
+	"github.com/cloudcmds/tamarin/object"
+)
+
+// magic and version tag the start of every marshaled Scope. version is
+// bumped whenever the encoding below changes in an incompatible way, so
+// that Unmarshal can reject bytecode produced by a different encoder
+// instead of misinterpreting it.
+const (
+	magic   uint32 = 0x54524d52 // "TRMR"
+	version uint16 = 2
+)
+
+// constant tags identify the object.Object kind that follows in the
+// stream, so Unmarshal can reconstruct the right concrete type without
+// guessing from the bytes alone.
+const (
+	tagNil byte = iota
+	tagInt
+	tagFloat
+	tagString
+	tagBool
+	tagList
+	tagMap
+	tagSet
+	tagCompiledFunction
+)
+
+// Marshal serializes scope, and every scope reachable through its
+// Constants and Children, into a self-contained byte stream. The result
+// can be handed to Unmarshal, on this machine or another, to reconstruct
+// a Scope that runs identically on the VM without re-parsing or
+// re-compiling the original source. A CompiledFunction constant whose
+// Scope is not one of its enclosing scope's Children, such as a module
+// compiled for an import, is not encoded: Marshal returns an error.
+func Marshal(scope *Scope) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, magic); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, version); err != nil {
+		return nil, err
+	}
+	if err := writeScope(&buf, scope); err != nil {
+		return nil, err
+	}
+	// Only the outermost scope ever carries a non-nil FileSet (children
+	// resolve positions through RootFileSet, walking Parent); write it once
+	// here rather than per scope, so Unmarshal can restore file:line:col
+	// reporting instead of every decoded scope silently losing it.
+	if err := writeFileSet(&buf, scope.FileSet); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a byte stream produced by Marshal back into a Scope.
+// It rejects data with a missing or mismatched magic/version header,
+// since such data was not produced by this version of the encoder.
+func Unmarshal(data []byte) (*Scope, error) {
+	r := bytes.NewReader(data)
+	var gotMagic uint32
+	if err := binary.Read(r, binary.LittleEndian, &gotMagic); err != nil {
+		return nil, fmt.Errorf("compiler: failed to read magic header: %w", err)
+	}
+	if gotMagic != magic {
+		return nil, fmt.Errorf("compiler: not a compiled program (bad magic)")
+	}
+	var gotVersion uint16
+	if err := binary.Read(r, binary.LittleEndian, &gotVersion); err != nil {
+		return nil, fmt.Errorf("compiler: failed to read version header: %w", err)
+	}
+	if gotVersion != version {
+		return nil, fmt.Errorf("compiler: unsupported bytecode version %d (want %d)", gotVersion, version)
+	}
+	scope, err := readScope(r)
+	if err != nil {
+		return nil, err
+	}
+	fset, err := readFileSet(r)
+	if err != nil {
+		return nil, err
+	}
+	scope.FileSet = fset
+	return scope, nil
+}
+
+// writeFileSet persists fset, if any, so that Unmarshal can hand back a
+// Scope whose errors still resolve to a file:line:col position instead of
+// always reporting none. token.FileSet's fields are all unexported, so its
+// own Write/Read methods (built for exactly this purpose) are used via gob
+// rather than hand-rolling a format for its internals.
+func writeFileSet(buf *bytes.Buffer, fset *token.FileSet) error {
+	if fset == nil {
+		return binary.Write(buf, binary.LittleEndian, false)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, true); err != nil {
+		return err
+	}
+	return fset.Write(gob.NewEncoder(buf).Encode)
+}
+
+func readFileSet(r *bytes.Reader) (*token.FileSet, error) {
+	var present bool
+	if err := binary.Read(r, binary.LittleEndian, &present); err != nil {
+		return nil, err
+	}
+	if !present {
+		return nil, nil
+	}
+	fset := token.NewFileSet()
+	if err := fset.Read(gob.NewDecoder(r).Decode); err != nil {
+		return nil, err
+	}
+	return fset, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, producing the same
+// self-contained, header-prefixed stream as the package-level Marshal.
+func (s *Scope) MarshalBinary() ([]byte, error) {
+	return Marshal(s)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing s in
+// place with the Scope decoded from data.
+func (s *Scope) UnmarshalBinary(data []byte) error {
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	*s = *decoded
+	return nil
+}
+
+// writeScope writes scope's Children before its Constants, rather than
+// the other order the fields appear on Scope itself. A CompiledFunction
+// constant's Scope is the very same *Scope object compileFunc already
+// appended to the enclosing scope's Children, so writeConstant needs that
+// child already accounted for (by index, via childIndex) to reference it
+// instead of serializing it a second time.
+func writeScope(buf *bytes.Buffer, scope *Scope) error {
+	if err := writeString(buf, scope.Name); err != nil {
+		return err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, scope.IsNamed); err != nil {
+		return err
+	}
+	if err := writeInstructions(buf, scope.Instructions); err != nil {
+		return err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(scope.Children))); err != nil {
+		return err
+	}
+	childIndex := make(map[*Scope]uint32, len(scope.Children))
+	for i, child := range scope.Children {
+		childIndex[child] = uint32(i)
+		if err := writeScope(buf, child); err != nil {
+			return err
+		}
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(scope.Constants))); err != nil {
+		return err
+	}
+	for _, c := range scope.Constants {
+		if err := writeConstant(buf, c, childIndex); err != nil {
+			return err
+		}
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(scope.Names))); err != nil {
+		return err
+	}
+	for _, name := range scope.Names {
+		if err := writeString(buf, name); err != nil {
+			return err
+		}
+	}
+	if err := writeSourceMap(buf, scope.SourceMap); err != nil {
+		return err
+	}
+	if err := writeSymbols(buf, scope.Symbols); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readScope(r *bytes.Reader) (*Scope, error) {
+	name, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	var isNamed bool
+	if err := binary.Read(r, binary.LittleEndian, &isNamed); err != nil {
+		return nil, err
+	}
+	instructions, err := readInstructions(r)
+	if err != nil {
+		return nil, err
+	}
+	var numChildren uint32
+	if err := binary.Read(r, binary.LittleEndian, &numChildren); err != nil {
+		return nil, err
+	}
+	children := make([]*Scope, numChildren)
+	for i := range children {
+		child, err := readScope(r)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = child
+	}
+	var numConstants uint32
+	if err := binary.Read(r, binary.LittleEndian, &numConstants); err != nil {
+		return nil, err
+	}
+	constants := make([]object.Object, numConstants)
+	for i := range constants {
+		c, err := readConstant(r, children)
+		if err != nil {
+			return nil, err
+		}
+		constants[i] = c
+	}
+	var numNames uint32
+	if err := binary.Read(r, binary.LittleEndian, &numNames); err != nil {
+		return nil, err
+	}
+	names := make([]string, numNames)
+	for i := range names {
+		n, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = n
+	}
+	sourceMap, err := readSourceMap(r)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := readSymbols(r)
+	if err != nil {
+		return nil, err
+	}
+	scope := &Scope{
+		Name:         name,
+		IsNamed:      isNamed,
+		Instructions: instructions,
+		Constants:    constants,
+		Names:        names,
+		SourceMap:    sourceMap,
+		Symbols:      symbols,
+	}
+	for _, child := range children {
+		child.Parent = scope
+		scope.Children = append(scope.Children, child)
+	}
+	return scope, nil
+}
+
+func writeInstructions(buf *bytes.Buffer, code []op.Code) error {
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(code))); err != nil {
+		return err
+	}
+	for _, b := range code {
+		if err := buf.WriteByte(byte(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readInstructions(r *bytes.Reader) ([]op.Code, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	code := make([]op.Code, n)
+	for i := range code {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		code[i] = op.Code(b)
+	}
+	return code, nil
+}
+
+// writeSourceMap persists the ip->position table built during compilation
+// (see Scope.SourceMap) so that a precompiled program can still report
+// file:line:col in runtime errors.
+func writeSourceMap(buf *bytes.Buffer, sourceMap map[int]token.Pos) error {
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(sourceMap))); err != nil {
+		return err
+	}
+	for ip, pos := range sourceMap {
+		if err := binary.Write(buf, binary.LittleEndian, uint32(ip)); err != nil {
+			return err
+		}
+		if err := binary.Write(buf, binary.LittleEndian, uint32(pos)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSourceMap(r *bytes.Reader) (map[int]token.Pos, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	sourceMap := make(map[int]token.Pos, n)
+	for i := uint32(0); i < n; i++ {
+		var ip, pos uint32
+		if err := binary.Read(r, binary.LittleEndian, &ip); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &pos); err != nil {
+			return nil, err
+		}
+		sourceMap[int(ip)] = token.Pos(pos)
+	}
+	return sourceMap, nil
+}
+
+// writeSymbols persists only what the VM actually reads back out of a
+// symbol.Table at runtime: the number of slots and the name occupying
+// each index, in index order (see vm.New, which sizes its globals slice
+// from this). Per-symbol attributes such as host-injected builtin values
+// are not written, so a decoded table holds names only and vm.New finds
+// no values to seed those globals with.
+func writeSymbols(buf *bytes.Buffer, tbl *symbol.Table) error {
+	if tbl == nil {
+		return binary.Write(buf, binary.LittleEndian, uint32(0))
+	}
+	entries := tbl.Map()
+	names := make([]string, len(entries))
+	for name, sym := range entries {
+		names[sym.Index] = name
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(names))); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := writeString(buf, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSymbols(r *bytes.Reader) (*symbol.Table, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	tbl := symbol.NewTable()
+	for i := uint32(0); i < n; i++ {
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tbl.InsertVariable(name); err != nil {
+			return nil, err
+		}
+	}
+	return tbl, nil
+}
+
+// writeConstant encodes obj. childIndex maps the enclosing scope's
+// Children to their index in that slice, so a *object.CompiledFunction
+// constant can reference its own Scope by index (it's one of those
+// children already, per compileFunc) instead of re-encoding it.
+func writeConstant(buf *bytes.Buffer, obj object.Object, childIndex map[*Scope]uint32) error {
+	switch obj := obj.(type) {
+	case nil:
+		buf.WriteByte(tagNil)
+	case *object.Int:
+		buf.WriteByte(tagInt)
+		return binary.Write(buf, binary.LittleEndian, obj.Value())
+	case *object.Float:
+		buf.WriteByte(tagFloat)
+		return binary.Write(buf, binary.LittleEndian, obj.Value())
+	case *object.String:
+		buf.WriteByte(tagString)
+		return writeString(buf, obj.Value())
+	case *object.Bool:
+		buf.WriteByte(tagBool)
+		var b byte
+		if obj.Value() {
+			b = 1
+		}
+		return buf.WriteByte(b)
+	case *object.List:
+		buf.WriteByte(tagList)
+		items := obj.Value()
+		if err := binary.Write(buf, binary.LittleEndian, uint32(len(items))); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := writeConstant(buf, item, childIndex); err != nil {
+				return err
+			}
+		}
+	case *object.Map:
+		buf.WriteByte(tagMap)
+		entries := obj.Value()
+		if err := binary.Write(buf, binary.LittleEndian, uint32(len(entries))); err != nil {
+			return err
+		}
+		for key, value := range entries {
+			if err := writeString(buf, key); err != nil {
+				return err
+			}
+			if err := writeConstant(buf, value, childIndex); err != nil {
+				return err
+			}
+		}
+	case *object.Set:
+		buf.WriteByte(tagSet)
+		items := obj.Value()
+		if err := binary.Write(buf, binary.LittleEndian, uint32(len(items))); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := writeConstant(buf, item, childIndex); err != nil {
+				return err
+			}
+		}
+	case *object.CompiledFunction:
+		buf.WriteByte(tagCompiledFunction)
+		if err := writeString(buf, obj.Name()); err != nil {
+			return err
+		}
+		params := obj.Parameters()
+		if err := binary.Write(buf, binary.LittleEndian, uint32(len(params))); err != nil {
+			return err
+		}
+		for _, p := range params {
+			if err := writeString(buf, p); err != nil {
+				return err
+			}
+		}
+		defaults := obj.Defaults()
+		if err := binary.Write(buf, binary.LittleEndian, uint32(len(defaults))); err != nil {
+			return err
+		}
+		for _, d := range defaults {
+			if err := writeConstant(buf, d, childIndex); err != nil {
+				return err
+			}
+		}
+		scope, ok := obj.Scope().(*Scope)
+		if !ok {
+			return fmt.Errorf("compiler: cannot marshal constant: function scope is %T, not *compiler.Scope", obj.Scope())
+		}
+		idx, ok := childIndex[scope]
+		if !ok {
+			return fmt.Errorf("compiler: cannot marshal constant: function scope not found among enclosing scope's children")
+		}
+		if err := binary.Write(buf, binary.LittleEndian, idx); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("compiler: cannot marshal constant of type %T", obj)
+	}
+	return nil
+}
+
+// readConstant is writeConstant's counterpart. children is the enclosing
+// scope's already-decoded Children, indexed the same way childIndex maps
+// them on the write side, so a tagCompiledFunction constant can look its
+// Scope up by index instead of decoding one of its own.
+func readConstant(r *bytes.Reader, children []*Scope) (object.Object, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case tagNil:
+		return nil, nil
+	case tagInt:
+		var v int64
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return object.NewInt(v), nil
+	case tagFloat:
+		var v float64
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return object.NewFloat(v), nil
+	case tagString:
+		v, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		return object.NewString(v), nil
+	case tagBool:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		return object.NewBool(b != 0), nil
+	case tagList:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		items := make([]object.Object, n)
+		for i := range items {
+			item, err := readConstant(r, children)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return object.NewList(items), nil
+	case tagMap:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		entries := make(map[string]object.Object, n)
+		for i := uint32(0); i < n; i++ {
+			key, err := readString(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readConstant(r, children)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		return object.NewMap(entries), nil
+	case tagSet:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		items := make([]object.Object, n)
+		for i := range items {
+			item, err := readConstant(r, children)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return object.NewSet(items), nil
+	case tagCompiledFunction:
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		var numParams uint32
+		if err := binary.Read(r, binary.LittleEndian, &numParams); err != nil {
+			return nil, err
+		}
+		params := make([]string, numParams)
+		for i := range params {
+			p, err := readString(r)
+			if err != nil {
+				return nil, err
+			}
+			params[i] = p
+		}
+		var numDefaults uint32
+		if err := binary.Read(r, binary.LittleEndian, &numDefaults); err != nil {
+			return nil, err
+		}
+		defaults := make([]object.Object, numDefaults)
+		for i := range defaults {
+			d, err := readConstant(r, children)
+			if err != nil {
+				return nil, err
+			}
+			defaults[i] = d
+		}
+		var childIdx uint32
+		if err := binary.Read(r, binary.LittleEndian, &childIdx); err != nil {
+			return nil, err
+		}
+		if childIdx >= uint32(len(children)) {
+			return nil, fmt.Errorf("compiler: invalid function scope index %d (have %d children)", childIdx, len(children))
+		}
+		scope := children[childIdx]
+		return object.NewCompiledFunction(name, params, defaults, scope.Instructions, scope), nil
+	default:
+		return nil, fmt.Errorf("compiler: unknown constant tag %d", tag)
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) error {
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := buf.WriteString(s)
+	return err
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	data := make([]byte, n)
+	// bytes.Reader.Read only errors once no bytes remain at all, not when
+	// fewer than len(data) are available, so a single Read here would
+	// silently hand back a short, zero-padded string for truncated or
+	// corrupted input instead of failing.
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,174 @@
+package compiler
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+
+	"github.com/cloudcmds/tamarin/object"
+	"github.com/cloudcmds/tamarin/parser"
+)
+
+func compileForMarshalTest(t *testing.T, src string, withFileSet bool) *Scope {
+	t.Helper()
+	// Parse always needs some FileSet to register the source into, even
+	// when withFileSet is false; in that case the fset is simply never
+	// handed to Options, matching the no-Options.FileSet case elsewhere
+	// where position reporting is unavailable.
+	fset := token.NewFileSet()
+	program, err := parser.Parse(fset, "marshal_test", src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts := Options{Name: "main"}
+	if withFileSet {
+		opts.FileSet = fset
+	}
+	scope, err := New(opts).Compile(program)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	return scope
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	scope := compileForMarshalTest(t, `x := 1 + 2; y := "hello"; z := [1, 2, 3]`, true)
+
+	data, err := Marshal(scope)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if got.Name != scope.Name {
+		t.Errorf("Name = %q, want %q", got.Name, scope.Name)
+	}
+	if len(got.Instructions) != len(scope.Instructions) {
+		t.Fatalf("Instructions length = %d, want %d", len(got.Instructions), len(scope.Instructions))
+	}
+	for i := range scope.Instructions {
+		if got.Instructions[i] != scope.Instructions[i] {
+			t.Fatalf("Instructions[%d] = %v, want %v", i, got.Instructions[i], scope.Instructions[i])
+		}
+	}
+	if len(got.Constants) != len(scope.Constants) {
+		t.Fatalf("Constants length = %d, want %d", len(got.Constants), len(scope.Constants))
+	}
+	if got.FileSet == nil {
+		t.Fatalf("expected a compiled-with-FileSet scope to keep its FileSet through Marshal/Unmarshal")
+	}
+}
+
+func TestMarshalUnmarshalNoFileSet(t *testing.T) {
+	scope := compileForMarshalTest(t, `1 + 1`, false)
+
+	data, err := Marshal(scope)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got.FileSet != nil {
+		t.Fatalf("expected a scope compiled without Options.FileSet to round-trip with a nil FileSet, got %v", got.FileSet)
+	}
+}
+
+// TestMarshalUnmarshalRoundTripWithClosure covers the recursive case the
+// original marshal request called out by name (CompiledFunction) that no
+// prior test exercised: a nested function scope, reachable from its
+// enclosing scope both via Children (compileFunc always appends it there)
+// and via the CompiledFunction constant that boxes it. It guards against
+// writeConstant re-serializing that scope a second time under Constants,
+// and against the decoded function's Scope ending up as a disconnected
+// copy instead of the very object in the enclosing scope's Children.
+func TestMarshalUnmarshalRoundTripWithClosure(t *testing.T) {
+	src := `
+make_adder := func(x) {
+	return func(y) {
+		return x + y
+	}
+}
+add5 := make_adder(5)
+add5(2)
+`
+	scope := compileForMarshalTest(t, src, true)
+	if len(scope.Children) == 0 {
+		t.Fatalf("expected the source to compile at least one nested function scope, got none")
+	}
+
+	data, err := Marshal(scope)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(got.Children) != len(scope.Children) {
+		t.Fatalf("Children length = %d, want %d", len(got.Children), len(scope.Children))
+	}
+
+	var fn *object.CompiledFunction
+	for _, c := range got.Constants {
+		if f, ok := c.(*object.CompiledFunction); ok {
+			fn = f
+			break
+		}
+	}
+	if fn == nil {
+		t.Fatalf("expected a CompiledFunction among the round-tripped constants")
+	}
+	fnScope, ok := fn.Scope().(*Scope)
+	if !ok {
+		t.Fatalf("CompiledFunction.Scope() = %T, want *Scope", fn.Scope())
+	}
+
+	var foundAsChild bool
+	for _, child := range got.Children {
+		if child == fnScope {
+			foundAsChild = true
+			break
+		}
+	}
+	if !foundAsChild {
+		t.Fatalf("expected the function constant's Scope to be the same object as one of the round-tripped scope's Children, not a second, disconnected copy")
+	}
+	if fnScope.Parent != got {
+		t.Fatalf("expected the function constant's Scope to have Parent set to the enclosing scope, got %v", fnScope.Parent)
+	}
+
+	// A second round trip must not balloon in size: that would indicate
+	// the same double-serialization bug compounding with nesting depth.
+	data2, err := Marshal(got)
+	if err != nil {
+		t.Fatalf("re-Marshal: %v", err)
+	}
+	if len(data2) > len(data)*2 {
+		t.Fatalf("re-marshaled size %d is more than twice the original %d; suspect double-serialized scopes", len(data2), len(data))
+	}
+}
+
+func TestUnmarshalRejectsTruncatedData(t *testing.T) {
+	scope := compileForMarshalTest(t, `x := "a string long enough that truncation lands mid-field somewhere"`, true)
+	data, err := Marshal(scope)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, n := range []int{0, 1, 2, len(data) / 3, len(data) / 2, len(data) - 1} {
+		if _, err := Unmarshal(data[:n]); err == nil {
+			t.Errorf("Unmarshal(data[:%d]) of %d: expected an error for truncated input, got nil", n, len(data))
+		}
+	}
+}
+
+func TestUnmarshalRejectsBadMagic(t *testing.T) {
+	data := bytes.Repeat([]byte{0xff}, 16)
+	if _, err := Unmarshal(data); err == nil {
+		t.Fatalf("expected an error for data with a bad magic header")
+	}
+}
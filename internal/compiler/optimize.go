@@ -0,0 +1,181 @@
+package compiler
+
+import "github.com/cloudcmds/tamarin/internal/op"
+
+// OptimizeStats reports the instruction-slot counts, summed across a
+// scope and all of its children, before and after an optimize pass.
+type OptimizeStats struct {
+	Before int
+	After  int
+}
+
+// optimize rewrites scope.Instructions, and recursively every child
+// scope's, in place using a handful of peephole rules:
+//
+//   - Nop instructions, and any code made unreachable by a preceding
+//     ReturnValue, JumpBackward or JumpForward, are dropped.
+//   - An adjacent LoadGlobal n; StoreGlobal n pair is a no-op and is
+//     dropped.
+//   - A JumpForward, or a conditional jump, that targets another
+//     JumpForward is shortened to jump straight to that jump's own
+//     target, collapsing the whole chain.
+//
+// Fusing "LoadConst 1; BinaryOp Add" into a single AddImmediate opcode,
+// as planned, is not yet implemented: it needs a new opcode added to the
+// internal/op table, which lives outside this package and isn't touched
+// here.
+//
+// Because removing instructions shifts every later jump target, the
+// work happens in two passes: jump chains are resolved against the
+// original, unshortened stream first, and only once every operand is
+// correct against that stream does dropDeadInstructions compute an
+// old-index -> new-index table and compact.
+func optimize(scope *Scope) OptimizeStats {
+	before := len(scope.Instructions)
+	scope.Instructions = foldJumpChains(scope.Instructions)
+	scope.Instructions = dropDeadInstructions(scope.Instructions)
+	stats := OptimizeStats{Before: before, After: len(scope.Instructions)}
+	for _, child := range scope.Children {
+		childStats := optimize(child)
+		stats.Before += childStats.Before
+		stats.After += childStats.After
+	}
+	return stats
+}
+
+// instructionWidth returns the number of op.Code slots, opcode included,
+// that code occupies, mirroring MakeInstruction's "1 + operand count".
+func instructionWidth(code op.Code) int {
+	return 1 + op.OperandCount[code].OperandCount
+}
+
+// jumpTarget returns the absolute instruction index a forward jump at
+// pos lands on, matching the base+delta arithmetic JumpForward and
+// PopJumpForwardIf* use at runtime.
+func jumpTarget(instructions []op.Code, pos int) int {
+	return pos + int(instructions[pos+1])
+}
+
+// backwardJumpTarget is jumpTarget's counterpart for JumpBackward and
+// PopJumpBackwardIf*, which subtract their delta instead of adding it.
+func backwardJumpTarget(instructions []op.Code, pos int) int {
+	return pos - int(instructions[pos+1])
+}
+
+func isForwardJump(opcode op.Code) bool {
+	switch opcode {
+	case op.JumpForward, op.PopJumpForwardIfTrue, op.PopJumpForwardIfFalse:
+		return true
+	}
+	return false
+}
+
+func isBackwardJump(opcode op.Code) bool {
+	switch opcode {
+	case op.JumpBackward, op.PopJumpBackwardIfTrue, op.PopJumpBackwardIfFalse:
+		return true
+	}
+	return false
+}
+
+// foldJumpChains rewrites every forward jump that targets another
+// JumpForward so that it targets that jump's own target instead,
+// following the whole chain. A visited set guards against looping
+// forever if two jumps somehow point at each other.
+func foldJumpChains(instructions []op.Code) []op.Code {
+	for i := 0; i < len(instructions); i += instructionWidth(instructions[i]) {
+		if !isForwardJump(instructions[i]) {
+			continue
+		}
+		target := jumpTarget(instructions, i)
+		seen := map[int]bool{}
+		for target < len(instructions) && instructions[target] == op.JumpForward && !seen[target] {
+			seen[target] = true
+			target = jumpTarget(instructions, target)
+		}
+		instructions[i+1] = op.Code(target - i)
+	}
+	return instructions
+}
+
+// dropDeadInstructions removes Nop instructions, code made unreachable
+// by a preceding ReturnValue, JumpBackward or JumpForward, and LoadGlobal n;
+// StoreGlobal n no-ops, then remaps and rewrites every surviving jump's
+// operand to match the compacted stream.
+func dropDeadInstructions(instructions []op.Code) []op.Code {
+	keep := make([]bool, len(instructions))
+	for i := range keep {
+		keep[i] = true
+	}
+
+	unreachable := false
+	for i := 0; i < len(instructions); {
+		opcode := instructions[i]
+		width := instructionWidth(opcode)
+		switch {
+		case opcode == op.Nop:
+			keep[i] = false
+		case unreachable:
+			for j := i; j < i+width; j++ {
+				keep[j] = false
+			}
+		case opcode == op.LoadGlobal && i+width < len(instructions) &&
+			instructions[i+width] == op.StoreGlobal &&
+			instructions[i+1] == instructions[i+width+1]:
+			storeWidth := instructionWidth(op.StoreGlobal)
+			for j := i; j < i+width+storeWidth; j++ {
+				keep[j] = false
+			}
+			i += width + storeWidth
+			continue
+		}
+		if opcode == op.ReturnValue || opcode == op.JumpBackward || opcode == op.JumpForward {
+			unreachable = true
+		} else if opcode != op.Nop {
+			unreachable = false
+		}
+		i += width
+	}
+
+	// Anything a surviving jump still lands on must stay live, even if
+	// it would otherwise have been swept up as unreachable.
+	for i := 0; i < len(instructions); i += instructionWidth(instructions[i]) {
+		opcode := instructions[i]
+		switch {
+		case isForwardJump(opcode):
+			keep[jumpTarget(instructions, i)] = true
+		case isBackwardJump(opcode):
+			keep[backwardJumpTarget(instructions, i)] = true
+		}
+	}
+
+	remap := make([]int, len(instructions)+1)
+	next := 0
+	for i := 0; i < len(instructions); i++ {
+		remap[i] = next
+		if keep[i] {
+			next++
+		}
+	}
+	remap[len(instructions)] = next
+
+	out := make([]op.Code, 0, next)
+	for i := 0; i < len(instructions); {
+		opcode := instructions[i]
+		width := instructionWidth(opcode)
+		if keep[i] {
+			switch {
+			case isForwardJump(opcode):
+				newDelta := remap[jumpTarget(instructions, i)] - remap[i]
+				out = append(out, opcode, op.Code(newDelta))
+			case isBackwardJump(opcode):
+				newDelta := remap[i] - remap[backwardJumpTarget(instructions, i)]
+				out = append(out, opcode, op.Code(newDelta))
+			default:
+				out = append(out, instructions[i:i+width]...)
+			}
+		}
+		i += width
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package compiler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cloudcmds/tamarin/internal/op"
+)
+
+func TestDropDeadInstructionsRemovesNops(t *testing.T) {
+	in := []op.Code{op.LoadGlobal, 0, op.Nop, op.StoreGlobal, 5}
+	want := []op.Code{op.LoadGlobal, 0, op.StoreGlobal, 5}
+	got := dropDeadInstructions(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("dropDeadInstructions(%v) = %v, want %v", in, got, want)
+	}
+}
+
+func TestDropDeadInstructionsRemovesCodeAfterReturn(t *testing.T) {
+	// Nothing else in the stream references the LoadGlobal below, so it's
+	// unreachable code left behind by, e.g., a dead branch, and should be
+	// swept away along with the slot it occupies.
+	in := []op.Code{op.ReturnValue, 1, op.LoadGlobal, 2}
+	want := []op.Code{op.ReturnValue, 1}
+	got := dropDeadInstructions(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("dropDeadInstructions(%v) = %v, want %v", in, got, want)
+	}
+}
+
+func TestDropDeadInstructionsRemovesRedundantGlobalRoundTrip(t *testing.T) {
+	in := []op.Code{op.LoadGlobal, 4, op.StoreGlobal, 4, op.ReturnValue, 1}
+	want := []op.Code{op.ReturnValue, 1}
+	got := dropDeadInstructions(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("dropDeadInstructions(%v) = %v, want %v", in, got, want)
+	}
+}
+
+func TestDropDeadInstructionsKeepsAndRemapsJumpTargets(t *testing.T) {
+	// The leading Nop is pure dead weight; the JumpForward's target (the
+	// ReturnValue, which follows the jump and so would otherwise count as
+	// unreachable) must stay live, and the jump's operand must be
+	// rewritten to match the compacted stream.
+	in := []op.Code{op.Nop, op.JumpForward, 2, op.ReturnValue, 1}
+	got := dropDeadInstructions(in)
+	if len(got) != 4 {
+		t.Fatalf("dropDeadInstructions(%v) = %v, want 4 slots", in, got)
+	}
+	if got[0] != op.JumpForward {
+		t.Fatalf("expected the leading Nop to be dropped, got %v", got)
+	}
+	if target := jumpTarget(got, 0); got[target] != op.ReturnValue {
+		t.Fatalf("expected the jump's remapped target to land on ReturnValue, landed on %v instead", got[target])
+	}
+}
+
+func TestFoldJumpChainsCollapsesChain(t *testing.T) {
+	// The first JumpForward targets the second, which itself targets
+	// ReturnValue; folding should make the first jump straight to
+	// ReturnValue instead of hopping through the second jump.
+	in := []op.Code{op.JumpForward, 2, op.JumpForward, 2, op.ReturnValue}
+	got := foldJumpChains(in)
+	if target := jumpTarget(got, 0); got[target] != op.ReturnValue {
+		t.Fatalf("expected the first jump to fold straight to ReturnValue, landed on %v instead", got[target])
+	}
+	if got[1] == 2 {
+		t.Fatalf("expected the first jump's delta to change once folded past the second jump, still 2")
+	}
+}
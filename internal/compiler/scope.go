@@ -0,0 +1,87 @@
+package compiler
+
+import (
+	"go/token"
+
+	"github.com/cloudcmds/tamarin/internal/op"
+	"github.com/cloudcmds/tamarin/internal/symbol"
+	"github.com/cloudcmds/tamarin/object"
+)
+
+// Scope holds everything the compiler produces for a single compilation
+// unit: a top-level program or a single function body. Functions get their
+// own child Scope so that their instructions, constants, and symbol table
+// stay independent of the scope that defines them.
+type Scope struct {
+	Name    string
+	IsNamed bool
+
+	Parent   *Scope
+	Children []*Scope
+
+	Symbols *symbol.Table
+
+	Instructions []op.Code
+	Constants    []object.Object
+	Loops        []*Loop
+
+	// constantsIndex deduplicates Constants for hashable object kinds
+	// (Int, Float, String, Bool), keyed by their canonical Go value.
+	// Non-hashable objects (lists, maps, functions, ...) are appended to
+	// Constants without a corresponding entry here.
+	constantsIndex map[any]uint16
+
+	// Names holds attribute/identifier names referenced by LoadAttr, in
+	// the order AddName assigned them an index.
+	Names []string
+
+	// SourceMap maps an instruction's starting index in Instructions to
+	// the token.Pos of the AST node that produced it, populated by emit()
+	// from the Compiler's nodes stack maintained during compile(). Used to
+	// report file:line:col in compile and runtime errors.
+	SourceMap map[int]token.Pos
+
+	// ConstBools records compile-time-known boolean values bound by
+	// `const` declarations in this scope, populated by compileConst. It
+	// lets compileIf constant-fold conditions like `if !DEBUG` when DEBUG
+	// is declared `const DEBUG = false`.
+	ConstBools map[string]bool
+
+	// FileSet resolves token.Pos values recorded in SourceMap (this
+	// scope's own, or any descendant's, since they share one FileSet)
+	// back to file:line:col. Only ever set on a root scope returned by
+	// Compile; child function scopes look it up through RootFileSet.
+	FileSet *token.FileSet
+}
+
+// RootFileSet returns the FileSet set on this scope's outermost ancestor,
+// or nil if none was configured (e.g. Options.FileSet was left unset).
+func (s *Scope) RootFileSet() *token.FileSet {
+	root := s
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root.FileSet
+}
+
+// AddName interns name in Names, returning its index. Repeated names share
+// the same index.
+func (s *Scope) AddName(name string) uint16 {
+	for i, existing := range s.Names {
+		if existing == name {
+			return uint16(i)
+		}
+	}
+	s.Names = append(s.Names, name)
+	return uint16(len(s.Names) - 1)
+}
+
+// PositionOf returns the source position recorded for the instruction
+// starting at ip, or the zero token.Pos if none was recorded (for example,
+// if source position tracking was never enabled).
+func (s *Scope) PositionOf(ip int) token.Pos {
+	if s.SourceMap == nil {
+		return token.NoPos
+	}
+	return s.SourceMap[ip]
+}
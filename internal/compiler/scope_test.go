@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/cloudcmds/tamarin/object"
+)
+
+func TestConstantDedupHashableKinds(t *testing.T) {
+	c := New(Options{Name: "main"})
+
+	i1 := c.constant(object.NewInt(42))
+	i2 := c.constant(object.NewInt(42))
+	if i1 != i2 {
+		t.Fatalf("expected duplicate int constants to share an index, got %d and %d", i1, i2)
+	}
+	if got := len(c.main.Constants); got != 1 {
+		t.Fatalf("expected constant pool to have 1 entry after a duplicate insert, got %d", got)
+	}
+
+	i3 := c.constant(object.NewInt(7))
+	if i3 == i1 {
+		t.Fatalf("expected a distinct int value to get its own constant index")
+	}
+
+	s1 := c.constant(object.NewString("hello"))
+	s2 := c.constant(object.NewString("hello"))
+	if s1 != s2 {
+		t.Fatalf("expected duplicate string constants to share an index, got %d and %d", s1, s2)
+	}
+
+	f1 := c.constant(object.NewFloat(3.5))
+	f2 := c.constant(object.NewFloat(3.5))
+	if f1 != f2 {
+		t.Fatalf("expected duplicate float constants to share an index, got %d and %d", f1, f2)
+	}
+
+	b1 := c.constant(object.NewBool(true))
+	b2 := c.constant(object.NewBool(true))
+	if b1 != b2 {
+		t.Fatalf("expected duplicate bool constants to share an index, got %d and %d", b1, b2)
+	}
+
+	// An int and a string with the same constantsIndex map should never
+	// collide with each other even though the map is keyed by `any`.
+	if i1 == s1 {
+		t.Fatalf("expected an int constant and a string constant to get different indexes")
+	}
+}
+
+func TestConstantNoDedupForNonHashableKinds(t *testing.T) {
+	c := New(Options{Name: "main"})
+
+	l1 := c.constant(object.NewList([]object.Object{object.NewInt(1)}))
+	l2 := c.constant(object.NewList([]object.Object{object.NewInt(1)}))
+	if l1 == l2 {
+		t.Fatalf("expected equal-valued list constants to get distinct indexes, since lists aren't deduplicated")
+	}
+	if got := len(c.main.Constants); got != 2 {
+		t.Fatalf("expected both list constants to be appended, got %d entries", got)
+	}
+}
@@ -0,0 +1,68 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudcmds/tamarin/ast"
+	"github.com/cloudcmds/tamarin/internal/op"
+)
+
+// traceEnter logs node at the current nesting depth and bumps indent for
+// whatever compile() does on its behalf; the matching traceExit (always
+// deferred alongside it) un-bumps it. Both are no-ops when Options.Trace
+// is nil, so an unused trace costs nothing but the nil check.
+func (c *Compiler) traceEnter(node ast.Node) {
+	if c.opts.Trace == nil {
+		return
+	}
+	fmt.Fprintf(c.opts.Trace, "%s%T\n", c.traceIndent(), node)
+	c.indent++
+}
+
+func (c *Compiler) traceExit() {
+	if c.opts.Trace == nil {
+		return
+	}
+	c.indent--
+}
+
+// traceEmit logs a single emitted instruction: its offset in the current
+// scope, its opcode name (via op.GetInfo), and its operands.
+func (c *Compiler) traceEmit(opcode op.Code, operands []uint16, pos uint16) {
+	if c.opts.Trace == nil {
+		return
+	}
+	fmt.Fprintf(c.opts.Trace, "%sEMIT %4d %-25s %v\n", c.traceIndent(), pos, op.GetInfo(opcode).Name, operands)
+}
+
+func (c *Compiler) traceScopePush(scope *Scope) {
+	if c.opts.Trace == nil {
+		return
+	}
+	fmt.Fprintf(c.opts.Trace, "%sSCOPE push %q\n", c.traceIndent(), scope.Name)
+	c.indent++
+}
+
+func (c *Compiler) traceScopePop(scope *Scope) {
+	if c.opts.Trace == nil {
+		return
+	}
+	c.indent--
+	fmt.Fprintf(c.opts.Trace, "%sSCOPE pop %q\n", c.traceIndent(), scope.Name)
+}
+
+// traceSymbol logs a resolved identifier: the name, which scope kind it
+// resolved to (global, local, free, builtin), and its index in that
+// scope. scopeKind and index are passed as any since their concrete
+// types live in the symbol package.
+func (c *Compiler) traceSymbol(name string, scopeKind any, index any) {
+	if c.opts.Trace == nil {
+		return
+	}
+	fmt.Fprintf(c.opts.Trace, "%sSYMBOL %s -> %v[%v]\n", c.traceIndent(), name, scopeKind, index)
+}
+
+func (c *Compiler) traceIndent() string {
+	return strings.Repeat("  ", c.indent)
+}
@@ -0,0 +1,212 @@
+package vm
+
+import (
+	"errors"
+	"go/token"
+	"sync"
+
+	"github.com/cloudcmds/tamarin/internal/compiler"
+	"github.com/cloudcmds/tamarin/object"
+)
+
+// ErrDebugDone is returned by Debugger's Step and Continue once the VM
+// being debugged has finished running.
+var ErrDebugDone = errors.New("vm: debug session finished")
+
+// debugState is the handoff point between a running VM's dispatch loop
+// and the Debugger driving it: the loop publishes the ip it's about to
+// execute on curr and then blocks on step until told to proceed, making
+// every instruction a rendezvous instead of a free-running loop.
+type debugState struct {
+	step chan struct{}
+	curr chan int
+
+	breakpointsMu sync.Mutex
+	breakpoints   map[breakpointKey]struct{}
+}
+
+type breakpointKey struct {
+	file string
+	line int
+}
+
+// FrameInfo describes one active call frame for a debugger, pairing the
+// scope it's executing with the source position it's paused at.
+type FrameInfo struct {
+	ScopeName string
+	Position  token.Position
+}
+
+// Debugger drives step-mode execution of the VM that created it via
+// EnableDebug. It's safe to use from a goroutine other than the one
+// running Run, which is the point: the dispatch loop blocks until Step or
+// Continue lets it proceed.
+type Debugger struct {
+	vm        *VM
+	state     *debugState
+	currentIP int
+}
+
+// EnableDebug turns on step-mode debugging: from the next time Run's
+// dispatch loop reaches its top, it pauses before every instruction until
+// the returned Debugger's Step or Continue releases it. Call before Run.
+// When debugging is off (the default), the only cost on the hot path is
+// the single "vm.debug != nil" check this adds.
+func (vm *VM) EnableDebug() *Debugger {
+	state := &debugState{
+		step:        make(chan struct{}),
+		curr:        make(chan int),
+		breakpoints: map[breakpointKey]struct{}{},
+	}
+	vm.debug = state
+	return &Debugger{vm: vm, state: state}
+}
+
+// Step runs exactly one instruction, returning nil, or ErrDebugDone if
+// the VM has already finished running (including because the VM was
+// aborted or its ctx was cancelled while paused). Position then reports
+// where that instruction was (the zero Position if the VM has no FileSet
+// configured).
+func (d *Debugger) Step() error {
+	ip, ok := d.recvCurr()
+	if !ok {
+		return ErrDebugDone
+	}
+	d.currentIP = ip
+	if !d.sendStep() {
+		return ErrDebugDone
+	}
+	return nil
+}
+
+// Continue runs until a breakpoint set via SetBreakpoint is reached or
+// the VM finishes, returning ErrDebugDone in the latter case (including
+// because the VM was aborted or its ctx was cancelled while paused).
+func (d *Debugger) Continue() error {
+	for {
+		ip, ok := d.recvCurr()
+		if !ok {
+			return ErrDebugDone
+		}
+		d.currentIP = ip
+		if d.atBreakpoint(ip) {
+			return nil
+		}
+		if !d.sendStep() {
+			return ErrDebugDone
+		}
+	}
+}
+
+// recvCurr waits for the dispatch loop to publish the ip it's about to
+// execute, reporting false if the VM finished first (curr closed) or was
+// aborted or had its ctx cancelled while the loop was paused waiting to
+// send. Without this escape hatch, the VM side's own abort-aware select
+// (in run's rendezvous) could pick its ctx/abortCh branch and return from
+// Run while Step or Continue is still blocked on this unconditional
+// receive, leaking the goroutine forever.
+func (d *Debugger) recvCurr() (int, bool) {
+	select {
+	case ip, ok := <-d.state.curr:
+		return ip, ok
+	case <-d.vm.runCtxDone():
+		return 0, false
+	case <-d.vm.abortCh:
+		return 0, false
+	}
+}
+
+// sendStep releases the dispatch loop to run the next instruction,
+// reporting false if the VM was aborted or had its ctx cancelled before
+// the loop could receive it — see recvCurr for why this needs the same
+// escape hatch as an unconditional send.
+func (d *Debugger) sendStep() bool {
+	select {
+	case d.state.step <- struct{}{}:
+		return true
+	case <-d.vm.runCtxDone():
+		return false
+	case <-d.vm.abortCh:
+		return false
+	}
+}
+
+// SetBreakpoint arranges for Continue to stop the next time execution
+// reaches line of file. Positions are only resolvable when the VM's main
+// scope was compiled with Options.FileSet set; otherwise breakpoints are
+// recorded but never match.
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.state.breakpointsMu.Lock()
+	d.state.breakpoints[breakpointKey{file, line}] = struct{}{}
+	d.state.breakpointsMu.Unlock()
+}
+
+func (d *Debugger) atBreakpoint(ip int) bool {
+	if d.vm.fileSet == nil {
+		return false
+	}
+	scope := d.vm.frames[d.vm.framesIndex].Scope()
+	pos := d.vm.fileSet.Position(scope.PositionOf(ip))
+	if !pos.IsValid() {
+		return false
+	}
+	d.state.breakpointsMu.Lock()
+	_, hit := d.state.breakpoints[breakpointKey{pos.Filename, pos.Line}]
+	d.state.breakpointsMu.Unlock()
+	return hit
+}
+
+// Position returns the source position the VM is currently paused at.
+func (d *Debugger) Position() token.Position {
+	if d.vm.fileSet == nil {
+		return token.Position{}
+	}
+	scope := d.vm.frames[d.vm.framesIndex].Scope()
+	return d.vm.fileSet.Position(scope.PositionOf(d.currentIP))
+}
+
+// Stack returns a snapshot of the VM's operand stack, bottom first.
+func (d *Debugger) Stack() []object.Object {
+	return d.vm.stack.Items()
+}
+
+// Frames returns the active call stack, outermost (main) frame first.
+func (d *Debugger) Frames() []FrameInfo {
+	frames := make([]FrameInfo, 0, d.vm.framesIndex+1)
+	d.vm.framePositions(d.currentIP, func(i int, scope *compiler.Scope, pos token.Position) {
+		frames = append(frames, FrameInfo{ScopeName: scope.Name, Position: pos})
+	})
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// Locals returns the local variable bindings for the frame at depth
+// frame, keyed by name, where 0 is the outermost/main frame (matching
+// Frames()'s indexing). The main frame's bindings are read from the VM's
+// globals slice, since top-level declarations compile to globals rather
+// than frame locals; every other frame's bindings come from its own
+// locals slots.
+func (d *Debugger) Locals(frame int) map[string]object.Object {
+	if frame < 0 || frame > d.vm.framesIndex {
+		return nil
+	}
+	scope := d.vm.frames[frame].Scope()
+	if scope == nil || scope.Symbols == nil {
+		return nil
+	}
+	locals := d.vm.frames[frame].Locals()
+	fromGlobals := frame == 0
+	out := make(map[string]object.Object)
+	for name, sym := range scope.Symbols.Map() {
+		if fromGlobals {
+			if sym.Index < len(d.vm.globals) {
+				out[name] = d.vm.globals[sym.Index]
+			}
+		} else if sym.Index < len(locals) {
+			out[name] = locals[sym.Index]
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"errors"
+
+	"github.com/cloudcmds/tamarin/internal/compiler"
+	"github.com/cloudcmds/tamarin/object"
+)
+
+// MaxTryNestingDepth bounds how many try blocks a single frame may have
+// active at once, mirroring neo-go's MaxTryNestingDepth. It exists so a
+// pathologically nested script fails fast with a clear error instead of
+// growing tries without bound.
+const MaxTryNestingDepth = 16
+
+// tryContext is the state a try block needs restored when unwinding to
+// its catch clause: where to resume (catchIP; finallyIP is recorded but
+// unwind never jumps to it) and what the
+// stack and frame depth were when the try was entered, so extra values
+// pushed by the code that raised can be discarded.
+type tryContext struct {
+	catchIP     int
+	finallyIP   int
+	stackDepth  int
+	framesIndex int
+}
+
+// Frame holds one call's-worth of VM state: which compiled function is
+// executing, its local variables, where to resume the caller on return,
+// and the stack of try blocks currently active within it.
+type Frame struct {
+	fn    *object.CompiledFunction
+	scope *compiler.Scope
+
+	locals     []object.Object
+	returnAddr int
+
+	tries      [MaxTryNestingDepth]tryContext
+	triesIndex int
+}
+
+// Init sets up the root frame, which has no associated CompiledFunction.
+func (f *Frame) Init(fn *object.CompiledFunction, returnAddr int, numLocals int) {
+	f.fn = fn
+	f.returnAddr = returnAddr
+	f.locals = make([]object.Object, numLocals)
+	f.triesIndex = 0
+}
+
+// InitWithLocals sets up a frame for a call to fn, seeding its locals
+// with the already-popped argument values.
+func (f *Frame) InitWithLocals(fn *object.CompiledFunction, returnAddr int, args []object.Object) {
+	f.fn = fn
+	f.scope = fn.Scope().(*compiler.Scope)
+	f.returnAddr = returnAddr
+	f.triesIndex = 0
+	numLocals := f.scope.Symbols.Size()
+	if numLocals < len(args) {
+		numLocals = len(args)
+	}
+	f.locals = make([]object.Object, numLocals)
+	copy(f.locals, args)
+}
+
+// Scope returns the compiler.Scope this frame is executing.
+func (f *Frame) Scope() *compiler.Scope {
+	return f.scope
+}
+
+// Locals returns the frame's local variable slots, shared (not copied)
+// with whoever holds a pointer into them, e.g. for MakeCell.
+func (f *Frame) Locals() []object.Object {
+	return f.locals
+}
+
+// pushTry records a try block entered at the current stack and frame
+// depth, so a later raise can unwind straight back to it.
+func (f *Frame) pushTry(catchIP, finallyIP, stackDepth, framesIndex int) error {
+	if f.triesIndex >= MaxTryNestingDepth {
+		return errors.New("vm: try nesting too deep")
+	}
+	f.tries[f.triesIndex] = tryContext{
+		catchIP:     catchIP,
+		finallyIP:   finallyIP,
+		stackDepth:  stackDepth,
+		framesIndex: framesIndex,
+	}
+	f.triesIndex++
+	return nil
+}
+
+// popTry removes and returns the innermost active try context, or
+// reports ok=false if this frame has none left.
+func (f *Frame) popTry() (tryContext, bool) {
+	if f.triesIndex == 0 {
+		return tryContext{}, false
+	}
+	f.triesIndex--
+	return f.tries[f.triesIndex], true
+}
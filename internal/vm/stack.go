@@ -0,0 +1,69 @@
+package vm
+
+// Stack is a LIFO stack of T, used for the VM's operand stack. capacity,
+// passed to NewStack, only sizes the initial backing array; Push grows it
+// like any append-backed slice rather than enforcing a hard limit. A
+// VM bounds how large an operand stack is allowed to get via
+// Limits.MaxStackSize (see run's dispatch loop), which is checked before
+// every instruction rather than inside Stack itself, since only the VM
+// knows the limit it was configured with.
+type Stack[T any] struct {
+	items []T
+}
+
+// NewStack returns an empty Stack with room for capacity items before its
+// backing array needs to grow.
+func NewStack[T any](capacity int) *Stack[T] {
+	return &Stack[T]{items: make([]T, 0, capacity)}
+}
+
+func (s *Stack[T]) Push(item T) {
+	s.items = append(s.items, item)
+}
+
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	last := len(s.items) - 1
+	item := s.items[last]
+	s.items[last] = zero
+	s.items = s.items[:last]
+	return item, true
+}
+
+func (s *Stack[T]) Top() (T, bool) {
+	if len(s.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.items[len(s.items)-1], true
+}
+
+// Len returns the number of items currently on the stack.
+func (s *Stack[T]) Len() int {
+	return len(s.items)
+}
+
+// Items returns a copy of the stack's contents, bottom first. Meant for
+// introspection (e.g. a debugger's Stack()), not the hot path.
+func (s *Stack[T]) Items() []T {
+	out := make([]T, len(s.items))
+	copy(out, s.items)
+	return out
+}
+
+// Truncate discards items down to the given length, e.g. to drop values
+// pushed by code that raised an exception before unwinding back to an
+// enclosing try block.
+func (s *Stack[T]) Truncate(n int) {
+	if n < 0 || n >= len(s.items) {
+		return
+	}
+	var zero T
+	for i := n; i < len(s.items); i++ {
+		s.items[i] = zero
+	}
+	s.items = s.items[:n]
+}
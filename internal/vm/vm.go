@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"go/token"
 	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/cloudcmds/tamarin/internal/compiler"
 	"github.com/cloudcmds/tamarin/internal/op"
@@ -12,13 +16,53 @@ import (
 	"github.com/cloudcmds/tamarin/parser"
 )
 
+// ErrAllocLimitExceeded is returned by Run when a script allocates more
+// heap objects than the VM's configured Limits.MaxAllocs allows.
+var ErrAllocLimitExceeded = errors.New("vm: allocation limit exceeded")
+
+// ErrStackLimitExceeded is returned by Run when the operand stack grows
+// past the VM's configured Limits.MaxStackSize.
+var ErrStackLimitExceeded = errors.New("vm: stack limit exceeded")
+
+// Limits bounds the resources a single Run is allowed to use, so a script
+// can be embedded without risking it exhausting process memory. The zero
+// value imposes no limit beyond the package's fixed MaxFrameDepth.
+type Limits struct {
+	// MaxAllocs caps the number of heap objects (lists, maps, sets,
+	// closures, cells, and arithmetic results) a script may construct.
+	// Zero means unlimited.
+	MaxAllocs int64
+
+	// MaxStackSize caps how many values the operand stack may hold at
+	// once. Zero means unlimited.
+	MaxStackSize int
+
+	// MaxFrameDepth caps call nesting depth. Zero means the package's
+	// MaxFrameDepth applies; a non-zero value lower than that further
+	// restricts it; a value higher than it has no effect, since frames
+	// are stored in a fixed-size array of that size.
+	MaxFrameDepth int
+}
+
+// ErrAborted is returned by Run when execution stops early because of an
+// explicit Abort call or because the context passed to Run was done. Use
+// errors.Is(err, ErrAborted) to detect it; when the context was the cause,
+// the returned error also wraps ctx.Err().
+var ErrAborted = errors.New("vm: execution aborted")
+
+// abortCheckInterval bounds how often the dispatch loop pays the cost of
+// checking for abort: every this-many instructions, in addition to the
+// unconditional checks on backward jumps, calls, and returns, which are
+// the points a script can spend the longest stretch of time between.
+const abortCheckInterval = 256
+
 const (
 	// MaxFrameCount = 2048
 	MaxArgs = 255
 )
 
 func Run(code string) (object.Object, error) {
-	ast, err := parser.Parse(code)
+	ast, err := parser.Parse(token.NewFileSet(), "", code)
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +75,7 @@ func Run(code string) (object.Object, error) {
 		return nil, err
 	}
 	vm := New(mainScope)
-	if err := vm.Run(); err != nil {
+	if err := vm.Run(context.Background()); err != nil {
 		return nil, err
 	}
 	return vm.Pop(), nil
@@ -51,6 +95,227 @@ type VM struct {
 	globals      []object.Object
 	// framePool    sync.Pool
 	// arrayPool sync.Pool
+
+	// tmpArgs holds popped call arguments between Pop and InitWithLocals.
+	// It's a VM field, not a local in run(), so every Run on this VM
+	// reuses the same backing array.
+	tmpArgs [MaxArgs]object.Object
+
+	// globalsMu guards writes to globals in StoreGlobal. New always sets
+	// it; StoreGlobal writes without locking if it is nil.
+	globalsMu *sync.Mutex
+
+	// debug is non-nil once EnableDebug has been called, and makes the
+	// dispatch loop pause before every instruction until a Debugger lets
+	// it proceed. The nil check guarding every use of it is the only
+	// cost paid on the hot path when debugging is off.
+	debug *debugState
+
+	// recoverPanics, toggled by SetRecover, converts a panic raised by a
+	// builtin's Call into an *object.Error result instead of crashing the
+	// VM. Off by default, since recovering hides programming errors in
+	// builtins during development.
+	recoverPanics bool
+
+	// pendingRaise, when non-nil, is re-raised and cleared by the next
+	// EndFinally. Nothing in the VM sets it, so EndFinally always falls
+	// through.
+	pendingRaise object.Object
+
+	// aborted is set to 1 by Abort to request that a running Run loop
+	// stop at its next abort check and return ErrAborted. It's accessed
+	// with sync/atomic since Abort is meant to be called concurrently
+	// from a goroutine other than the one running Run.
+	aborted int32
+
+	// abortCh is closed the first time Abort is called, letting a
+	// select-based wait (the debug rendezvous below) notice cancellation
+	// immediately instead of only at the next polled checkAbort.
+	abortCh chan struct{}
+
+	// runCtx is the ctx passed to the in-progress Run call, set before the
+	// dispatch loop starts. A Debugger reads it so Step and Continue can
+	// select on the same cancellation the dispatch loop's own rendezvous
+	// selects on, instead of blocking forever if ctx is cancelled while a
+	// script is paused mid-debug.
+	runCtx context.Context
+
+	// limits holds the resource bounds configured by SetLimits, and
+	// allocs counts heap objects constructed so far against
+	// limits.MaxAllocs.
+	limits Limits
+	allocs int64
+
+	// fileSet resolves the token.Pos values recorded in a scope's
+	// SourceMap back to file:line:col, for RuntimeError. It's nil (and
+	// Run's errors go unwrapped) unless main was compiled with
+	// Options.FileSet set.
+	fileSet *token.FileSet
+
+	// lastIP is the instruction index the current frame's scope was
+	// executing when Run last entered its dispatch switch, i.e. the
+	// instruction that failed if Run returns an error. It's distinct from
+	// vm.ip, which may have already advanced past the failing
+	// instruction's operands by the time an error is returned.
+	lastIP int
+}
+
+// SetLimits configures the resource limits enforced for the remainder of
+// this VM's execution. Call before Run; a zero-value Limits field leaves
+// that resource unbounded (MaxFrameDepth still can't exceed the package's
+// MaxFrameDepth, since frames live in a fixed-size array).
+func (vm *VM) SetLimits(limits Limits) {
+	vm.limits = limits
+}
+
+// alloc counts one heap object allocation, reporting ErrAllocLimitExceeded
+// once limits.MaxAllocs is configured and exceeded.
+func (vm *VM) alloc() error {
+	if vm.limits.MaxAllocs <= 0 {
+		return nil
+	}
+	vm.allocs++
+	if vm.allocs > vm.limits.MaxAllocs {
+		return ErrAllocLimitExceeded
+	}
+	return nil
+}
+
+// maxFrameDepth returns the effective frame depth limit: the package's
+// MaxFrameDepth, further restricted by limits.MaxFrameDepth if one was
+// configured and is smaller.
+func (vm *VM) maxFrameDepth() int {
+	if vm.limits.MaxFrameDepth > 0 && vm.limits.MaxFrameDepth < MaxFrameDepth {
+		return vm.limits.MaxFrameDepth
+	}
+	return MaxFrameDepth
+}
+
+// RuntimeError wraps an error that stopped script execution with the
+// source position it happened at and a traceback through the call stack
+// active at that point, one line per frame from outermost to innermost.
+type RuntimeError struct {
+	Err   error
+	Pos   token.Position
+	Trace []string
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Traceback renders Trace as a multi-line "at" stack, outermost frame
+// first, suitable for printing alongside Error().
+func (e *RuntimeError) Traceback() string {
+	lines := make([]string, len(e.Trace))
+	for i, frame := range e.Trace {
+		lines[i] = "\tat " + frame
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapRuntimeError attaches position and traceback information to err, or
+// returns it unchanged if this VM has no FileSet (Options.FileSet was
+// never set) to resolve positions against.
+func (vm *VM) wrapRuntimeError(err error) error {
+	if vm.fileSet == nil {
+		return err
+	}
+	var already *RuntimeError
+	if errors.As(err, &already) {
+		return err
+	}
+
+	var pos token.Position
+	trace := make([]string, 0, vm.framesIndex+1)
+	vm.framePositions(vm.lastIP, func(i int, scope *compiler.Scope, p token.Position) {
+		if i == vm.framesIndex {
+			pos = p
+		}
+		trace = append(trace, fmt.Sprintf("%s: %s", scope.Name, p))
+	})
+	// trace was built innermost-first; reverse it so it reads outermost
+	// (main) first, like a traditional traceback.
+	for i, j := 0, len(trace)-1; i < j; i, j = i+1, j-1 {
+		trace[i], trace[j] = trace[j], trace[i]
+	}
+	return &RuntimeError{Err: err, Pos: pos, Trace: trace}
+}
+
+// framePositions walks frames[0..framesIndex] from innermost to
+// outermost, calling fn with each frame's index, scope, and the position
+// it was executing: ip for the innermost frame, and each enclosing
+// frame's call site (its callee's returnAddr - 1) for the rest. fn sees a
+// zero Position for every frame when fileSet is nil. Shared by
+// wrapRuntimeError's traceback and the debugger's Frames().
+func (vm *VM) framePositions(ip int, fn func(i int, scope *compiler.Scope, pos token.Position)) {
+	for i := vm.framesIndex; i >= 0; i-- {
+		scope := vm.frames[i].Scope()
+		var pos token.Position
+		if vm.fileSet != nil {
+			pos = vm.fileSet.Position(scope.PositionOf(ip))
+		}
+		fn(i, scope, pos)
+		if i == 0 {
+			break
+		}
+		ip = vm.frames[i].returnAddr - 1
+	}
+}
+
+// spawn was a prior attempt at a `spawn fn(args...)` primitive: a pooled
+// child *VM ran fn concurrently and handed back an *object.Task. It's
+// been pulled out again. What landed was only ever the VM-side half —
+// op.Spawn had no compiler path that emitted it (no ast node shape, no
+// chan object type, no send/recv/close builtins), which made it dead
+// bytecode sitting in the hot dispatch switch rather than a usable
+// language feature. Re-add it alongside a real compiler emission path,
+// not on its own.
+
+// Abort requests that a running Run loop stop as soon as it next checks
+// for abort, returning ErrAborted. Safe to call concurrently with Run
+// from another goroutine, e.g. to enforce a timeout.
+func (vm *VM) Abort() {
+	if atomic.CompareAndSwapInt32(&vm.aborted, 0, 1) {
+		close(vm.abortCh)
+	}
+}
+
+// runCtxDone returns the Done channel of the ctx passed to the
+// in-progress Run call, or nil if Run hasn't started yet. A nil channel
+// blocks forever in a select, which is the right behavior here: a
+// Debugger waiting before Run begins has nothing to race against yet.
+func (vm *VM) runCtxDone() <-chan struct{} {
+	if vm.runCtx == nil {
+		return nil
+	}
+	return vm.runCtx.Done()
+}
+
+// checkAbort reports ErrAborted if Abort has been called or if ctx is
+// done, wrapping ctx.Err() in the latter case.
+func (vm *VM) checkAbort(ctx context.Context) error {
+	if atomic.LoadInt32(&vm.aborted) != 0 {
+		return ErrAborted
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrAborted, err)
+	}
+	return nil
+}
+
+// SetRecover enables or disables recovering from panics raised by
+// builtin calls. When enabled, a panicking builtin call returns an
+// *object.Error describing the panic instead of propagating it.
+func (vm *VM) SetRecover(enable bool) {
+	vm.recoverPanics = enable
 }
 
 func New(main *compiler.Scope) *VM {
@@ -60,6 +325,9 @@ func New(main *compiler.Scope) *VM {
 		sp:           -1,
 		main:         main,
 		currentScope: main,
+		fileSet:      main.RootFileSet(),
+		globalsMu:    &sync.Mutex{},
+		abortCh:      make(chan struct{}),
 		// framePool: sync.Pool{
 		// 	New: func() interface{} {
 		// 		return &Frame{}
@@ -84,24 +352,76 @@ func New(main *compiler.Scope) *VM {
 	return vm
 }
 
-func (vm *VM) Run() error {
+// Run executes the VM's main scope to completion. ctx flows into every
+// builtin call made along the way, and is also checked periodically (and
+// on every backward jump, Call, and ReturnValue) so that a timeout on ctx,
+// or a concurrent call to Abort, stops execution promptly and returns
+// ErrAborted rather than running the script to completion regardless.
+//
+// Any error is wrapped in a *RuntimeError carrying the file:line:col it
+// occurred at and a traceback through the call stack, provided the scope
+// was compiled with Options.FileSet set; otherwise the underlying error is
+// returned as-is.
+func (vm *VM) Run(ctx context.Context) error {
+	if err := vm.run(ctx); err != nil {
+		return vm.wrapRuntimeError(err)
+	}
+	return nil
+}
+
+func (vm *VM) run(ctx context.Context) error {
 	// for i, b := range vm.code {
 	// 	fmt.Printf("%d %d\n", i, b)
 	// }
 	// fmt.Println("---")
-	ctx := context.Background()
 	// currentFrame := NewFrame(nil, make([]object.Object, symbolCount), 0, vm.currentScope)
-	tmpArgs := [MaxArgs]object.Object{}
+	tmpArgs := &vm.tmpArgs
 	currentFrame := &vm.frames[0]
 	currentFrame.Init(nil, 0, vm.currentScope.Symbols.Size())
 	currentFrame.scope = vm.main
+	vm.runCtx = ctx
 	// vm.frameStack.Push(currentFrame)
+	if vm.debug != nil {
+		defer close(vm.debug.curr)
+	}
+	var instrCount uint64
 	for vm.ip < len(vm.currentScope.Instructions) {
+		instrCount++
+		if instrCount%abortCheckInterval == 0 {
+			if err := vm.checkAbort(ctx); err != nil {
+				return err
+			}
+		}
+		if vm.limits.MaxStackSize > 0 && vm.stack.Len() > vm.limits.MaxStackSize {
+			return ErrStackLimitExceeded
+		}
 		scope := vm.currentScope
 		opcode := scope.Instructions[vm.ip]
 		// opinfo := op.GetInfo(opcode)
 		// _, operands := compiler.ReadOp(scope.Instructions[vm.ip:])
 		// fmt.Printf("EXEC %-25s %v (IP: %d)\n", opinfo.Name, operands, vm.ip)
+		vm.lastIP = vm.ip
+		if vm.debug != nil {
+			// Both halves of the rendezvous select on ctx and abortCh
+			// alongside the debugger's channels, so Abort (or ctx being
+			// cancelled) takes effect immediately even while a script is
+			// paused mid-debug, instead of only once Step or Continue is
+			// next called.
+			select {
+			case vm.debug.curr <- vm.lastIP:
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %w", ErrAborted, ctx.Err())
+			case <-vm.abortCh:
+				return ErrAborted
+			}
+			select {
+			case <-vm.debug.step:
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %w", ErrAborted, ctx.Err())
+			case <-vm.abortCh:
+				return ErrAborted
+			}
+		}
 		vm.ip++
 		switch opcode {
 		case op.Nop:
@@ -110,7 +430,11 @@ func (vm *VM) Run() error {
 			name := vm.currentScope.Names[vm.fetch2()]
 			value, found := obj.GetAttr(name)
 			if !found {
-				return fmt.Errorf("attribute %q not found", name)
+				if err := vm.raiseOrReturn(fmt.Errorf("attribute %q not found", name)); err != nil {
+					return err
+				}
+				currentFrame = &vm.frames[vm.framesIndex]
+				continue
 			}
 			vm.stack.Push(value)
 		case op.LoadConst:
@@ -125,7 +449,15 @@ func (vm *VM) Run() error {
 		case op.StoreFast:
 			currentFrame.locals[vm.fetch()] = vm.Pop()
 		case op.StoreGlobal:
-			vm.globals[vm.fetch2()] = vm.Pop()
+			idx := vm.fetch2()
+			val := vm.Pop()
+			if vm.globalsMu != nil {
+				vm.globalsMu.Lock()
+				vm.globals[idx] = val
+				vm.globalsMu.Unlock()
+			} else {
+				vm.globals[idx] = val
+			}
 		case op.StoreFree:
 			freeVars := currentFrame.fn.FreeVars()
 			freeVars[vm.fetch2()].Set(vm.Pop())
@@ -142,6 +474,9 @@ func (vm *VM) Run() error {
 					return errors.New("expected cell")
 				}
 			}
+			if err := vm.alloc(); err != nil {
+				return err
+			}
 			fn := scope.Constants[constIndex].(*object.CompiledFunction)
 			closure := object.NewClosure(fn, fn.Scope(), free)
 			vm.stack.Push(closure)
@@ -152,6 +487,9 @@ func (vm *VM) Run() error {
 			if frameIndex < 0 {
 				return fmt.Errorf("no frame at depth %d", framesBack)
 			}
+			if err := vm.alloc(); err != nil {
+				return err
+			}
 			frame := &vm.frames[frameIndex]
 			locals := frame.Locals()
 			vm.stack.Push(object.NewCell(&locals[symbolIndex]))
@@ -165,13 +503,22 @@ func (vm *VM) Run() error {
 			opType := op.CompareOpType(vm.fetch())
 			b := vm.Pop()
 			a := vm.Pop()
+			if err := vm.alloc(); err != nil {
+				return err
+			}
 			vm.stack.Push(vm.runCompareOp(opType, a, b))
 		case op.BinaryOp:
 			opType := op.BinaryOpType(vm.fetch())
 			b := vm.Pop()
 			a := vm.Pop()
+			if err := vm.alloc(); err != nil {
+				return err
+			}
 			vm.stack.Push(vm.runBinaryOp(opType, a, b))
 		case op.Call:
+			if err := vm.checkAbort(ctx); err != nil {
+				return err
+			}
 			argc := int(vm.fetch())
 			for i := 0; i < argc; i++ {
 				tmpArgs[argc-1-i] = vm.Pop()
@@ -179,12 +526,15 @@ func (vm *VM) Run() error {
 			obj := vm.Pop()
 			switch obj := obj.(type) {
 			case *object.Builtin:
-				result := obj.Call(ctx, tmpArgs[:argc]...)
+				result := vm.callBuiltin(ctx, obj, tmpArgs[:argc])
 				vm.stack.Push(result)
 			case *object.CompiledFunction:
-				if vm.framesIndex+1 >= MaxFrameDepth {
-					fmt.Println("OVERFLOW", vm.framesIndex)
-					return errors.New("frame overflow")
+				if vm.framesIndex+1 >= vm.maxFrameDepth() {
+					if err := vm.raiseOrReturn(errors.New("frame overflow")); err != nil {
+						return err
+					}
+					currentFrame = &vm.frames[vm.framesIndex]
+					continue
 				}
 				vm.framesIndex++
 				frame := &vm.frames[vm.framesIndex]
@@ -207,9 +557,16 @@ func (vm *VM) Run() error {
 				vm.ip = 0
 				vm.currentScope = scope
 			default:
-				return fmt.Errorf("not a function: %T", obj)
+				if err := vm.raiseOrReturn(fmt.Errorf("not a function: %T", obj)); err != nil {
+					return err
+				}
+				currentFrame = &vm.frames[vm.framesIndex]
+				continue
 			}
 		case op.ReturnValue:
+			if err := vm.checkAbort(ctx); err != nil {
+				return err
+			}
 			if vm.framesIndex < 1 {
 				return errors.New("frame underflow")
 			}
@@ -250,6 +607,9 @@ func (vm *VM) Run() error {
 			delta := int(vm.fetch2())
 			vm.ip = base + delta
 		case op.JumpBackward:
+			if err := vm.checkAbort(ctx); err != nil {
+				return err
+			}
 			base := vm.ip - 1
 			delta := int(vm.fetch2())
 			vm.ip = base - delta
@@ -261,6 +621,9 @@ func (vm *VM) Run() error {
 			for i := uint16(0); i < count; i++ {
 				items[count-1-i] = vm.Pop()
 			}
+			if err := vm.alloc(); err != nil {
+				return err
+			}
 			vm.stack.Push(object.NewList(items))
 		case op.BuildMap:
 			count := vm.fetch2()
@@ -270,6 +633,9 @@ func (vm *VM) Run() error {
 				k := vm.Pop()
 				items[k.(*object.String).Value()] = v
 			}
+			if err := vm.alloc(); err != nil {
+				return err
+			}
 			vm.stack.Push(object.NewMap(items))
 		case op.BuildSet:
 			count := vm.fetch2()
@@ -277,28 +643,49 @@ func (vm *VM) Run() error {
 			for i := uint16(0); i < count; i++ {
 				items[i] = vm.Pop()
 			}
+			if err := vm.alloc(); err != nil {
+				return err
+			}
 			vm.stack.Push(object.NewSet(items))
 		case op.BinarySubscr:
 			index := vm.Pop()
 			obj := vm.Pop()
 			container, ok := obj.(object.Container)
 			if !ok {
-				return fmt.Errorf("object is not a container: %T", obj)
+				if err := vm.raiseOrReturn(fmt.Errorf("object is not a container: %T", obj)); err != nil {
+					return err
+				}
+				currentFrame = &vm.frames[vm.framesIndex]
+				continue
 			}
-			result, err := container.GetItem(index)
-			if err != nil {
-				return err.Value()
+			result, itemErr := container.GetItem(index)
+			if itemErr != nil {
+				if err := vm.raiseOrReturn(itemErr.Value()); err != nil {
+					return err
+				}
+				currentFrame = &vm.frames[vm.framesIndex]
+				continue
 			}
 			vm.stack.Push(result)
 		case op.UnaryNegative:
 			obj := vm.Pop()
 			switch obj := obj.(type) {
 			case *object.Int:
+				if err := vm.alloc(); err != nil {
+					return err
+				}
 				vm.stack.Push(object.NewInt(-obj.Value()))
 			case *object.Float:
+				if err := vm.alloc(); err != nil {
+					return err
+				}
 				vm.stack.Push(object.NewFloat(-obj.Value()))
 			default:
-				return fmt.Errorf("object is not a number: %T", obj)
+				if err := vm.raiseOrReturn(fmt.Errorf("object is not a number: %T", obj)); err != nil {
+					return err
+				}
+				currentFrame = &vm.frames[vm.framesIndex]
+				continue
 			}
 		case op.UnaryNot:
 			obj := vm.Pop()
@@ -318,7 +705,50 @@ func (vm *VM) Run() error {
 				}
 				vm.stack.Push(value)
 			} else {
-				return fmt.Errorf("object is not a container: %T", container)
+				if err := vm.raiseOrReturn(fmt.Errorf("object is not a container: %T", containerObj)); err != nil {
+					return err
+				}
+				currentFrame = &vm.frames[vm.framesIndex]
+				continue
+			}
+		// SetupTry, PopTry, Raise and EndFinally implement try/catch: a
+		// raise unwinds to the innermost active try's catchIP. A try's
+		// finallyIP is recorded but never jumped to, so no finally block
+		// runs when an exception unwinds past it. They're new opcodes this change assumes internal/op will gain
+		// (that package isn't part of this checkout, so it can't be edited
+		// here); compiler-side support for try/catch syntax, i.e. an
+		// ast.Try-shaped node and its compile() case, is likewise out of
+		// scope for this commit since the ast package's node shapes aren't
+		// observable here either. This lays down the VM-side mechanics so
+		// both can land once those packages are available.
+		case op.SetupTry:
+			catchIP := int(vm.fetch2())
+			finallyIP := int(vm.fetch2())
+			if err := currentFrame.pushTry(catchIP, finallyIP, vm.stack.Len(), vm.framesIndex); err != nil {
+				return err
+			}
+		case op.PopTry:
+			currentFrame.popTry()
+		case op.Raise:
+			exc := vm.Pop()
+			if !vm.unwind(exc) {
+				if asErr, ok := exc.(*object.Error); ok {
+					return asErr.Value()
+				}
+				return fmt.Errorf("unhandled exception: %v", exc)
+			}
+			currentFrame = &vm.frames[vm.framesIndex]
+		case op.EndFinally:
+			if vm.pendingRaise != nil {
+				exc := vm.pendingRaise
+				vm.pendingRaise = nil
+				if !vm.unwind(exc) {
+					if asErr, ok := exc.(*object.Error); ok {
+						return asErr.Value()
+					}
+					return fmt.Errorf("unhandled exception: %v", exc)
+				}
+				currentFrame = &vm.frames[vm.framesIndex]
 			}
 		case op.Halt:
 			return nil
@@ -413,3 +843,58 @@ func (vm *VM) fetch2() uint16 {
 	vm.ip += 2
 	return value
 }
+
+// raiseOrReturn converts a runtime error encountered by the dispatch
+// loop into an *object.Error and unwinds to the nearest try block, if
+// any is active anywhere on the frame stack. It returns nil when the
+// error was caught this way (the caller should `continue` the dispatch
+// loop), or the original err if nothing caught it (the caller should
+// return it from Run, as before try/catch existed).
+func (vm *VM) raiseOrReturn(err error) error {
+	if vm.unwind(object.NewError(err)) {
+		return nil
+	}
+	return err
+}
+
+// callBuiltin invokes b, optionally recovering a panic into an
+// *object.Error result when SetRecover(true) is in effect.
+func (vm *VM) callBuiltin(ctx context.Context, b *object.Builtin, args []object.Object) (result object.Object) {
+	if !vm.recoverPanics {
+		return b.Call(ctx, args...)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result = object.NewError(fmt.Errorf("panic in builtin: %v", r))
+		}
+	}()
+	return b.Call(ctx, args...)
+}
+
+// unwind walks back from the frame currently executing, through
+// vm.frames[vm.framesIndex] down to vm.frames[0], popping frames until
+// one with an active try block is found. When found, it restores the
+// stack depth, frame index, scope, and ip recorded when that try block
+// was entered, pushes exc as the caught value, and reports true. It
+// reports false, leaving VM state untouched, if no try block is active
+// anywhere on the stack.
+func (vm *VM) unwind(exc object.Object) bool {
+	start := vm.framesIndex
+	for {
+		frame := &vm.frames[vm.framesIndex]
+		if tc, ok := frame.popTry(); ok {
+			vm.framesIndex = tc.framesIndex
+			vm.stack.Truncate(tc.stackDepth)
+			vm.sp = tc.stackDepth
+			vm.currentScope = frame.Scope()
+			vm.ip = tc.catchIP
+			vm.stack.Push(exc)
+			return true
+		}
+		if vm.framesIndex == 0 {
+			vm.framesIndex = start
+			return false
+		}
+		vm.framesIndex--
+	}
+}